@@ -0,0 +1,306 @@
+// Package client calls the booking API. Every call sends correlation ID of its context (see pkg.SetCorrelationID),
+// problems returned by the server are decoded into ProblemError and failed calls are retried. Calls changing
+// bookings are sent with Idempotency-Key, so that retries do not book twice.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+const CapabilityPricing = "pricing"
+
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = 100 * time.Millisecond
+)
+
+type Option func(c *Client)
+
+// WithHTTPClient sets client used to send requests, http.DefaultClient is used by default
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithCapability extends returned objects, e.g. CapabilityPricing adds prices
+func WithCapability(capability string) Option {
+	return func(c *Client) {
+		c.capability = capability
+	}
+}
+
+// WithRetries sets how many times failed call is retried, delay before retry doubles with every attempt
+func WithRetries(retries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryDelay = delay
+	}
+}
+
+// New creates client of API at baseURL, e.g. http://localhost:8080
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("base URL is invalid: %w", err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("base URL %s must be absolute", baseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(parsed.String(), "/"),
+		httpClient: http.DefaultClient,
+		retries:    defaultRetries,
+		retryDelay: defaultRetryDelay,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	capability string
+	retries    int
+	retryDelay time.Duration
+}
+
+type idempotencyKeyCtxKey struct{}
+
+// WithIdempotencyKey sets Idempotency-Key of calls changing bookings made with ctx, a new key is generated for every
+// call by default. Set it to retry the call after the client gave up, e.g. after restart.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtxKey{}, key)
+}
+
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyCtxKey{}).(string); ok && key != "" {
+		return key
+	}
+	return uuid.NewString()
+}
+
+func (c *Client) ListProducts(ctx context.Context) ([]Product, error) {
+	var products []Product
+	err := c.do(ctx, http.MethodGet, "/api/v1/products", nil, "", &products)
+	return products, err
+}
+
+func (c *Client) GetProduct(ctx context.Context, id uuid.UUID) (Product, error) {
+	var product Product
+	err := c.do(ctx, http.MethodGet, "/api/v1/products/"+id.String(), nil, "", &product)
+	return product, err
+}
+
+// GetAvailability returns availabilities of product on day
+func (c *Client) GetAvailability(ctx context.Context, productID uuid.UUID, day Date) ([]Availability, error) {
+	request := struct {
+		ProductID uuid.UUID `json:"productId"`
+		LocalDate Date      `json:"localDate"`
+	}{
+		ProductID: productID,
+		LocalDate: day,
+	}
+	var availabilities []Availability
+	err := c.do(ctx, http.MethodPost, "/api/v1/availability", request, "", &availabilities)
+	return availabilities, err
+}
+
+// GetAvailabilityRange returns availabilities of product from start to end including both
+func (c *Client) GetAvailabilityRange(ctx context.Context, productID uuid.UUID, start Date, end Date) ([]Availability, error) {
+	request := struct {
+		ProductID      uuid.UUID `json:"productId"`
+		LocalDateStart Date      `json:"localDateStart"`
+		LocalDateEnd   Date      `json:"localDateEnd"`
+	}{
+		ProductID:      productID,
+		LocalDateStart: start,
+		LocalDateEnd:   end,
+	}
+	var availabilities []Availability
+	err := c.do(ctx, http.MethodPost, "/api/v1/availability", request, "", &availabilities)
+	return availabilities, err
+}
+
+// GetProductsAvailability returns availabilities of productIDs from start to end including both grouped by product,
+// availabilities of all products are returned when productIDs is empty
+func (c *Client) GetProductsAvailability(ctx context.Context, productIDs []uuid.UUID, start Date, end Date) ([]ProductAvailabilities, error) {
+	request := struct {
+		ProductIDs     []uuid.UUID `json:"productIds,omitempty"`
+		AllProducts    bool        `json:"allProducts,omitempty"`
+		LocalDateStart Date        `json:"localDateStart"`
+		LocalDateEnd   Date        `json:"localDateEnd"`
+	}{
+		ProductIDs:     productIDs,
+		AllProducts:    len(productIDs) == 0,
+		LocalDateStart: start,
+		LocalDateEnd:   end,
+	}
+	var products []ProductAvailabilities
+	err := c.do(ctx, http.MethodPost, "/api/v1/availability", request, "", &products)
+	return products, err
+}
+
+// CreateBooking reserves units of availability
+func (c *Client) CreateBooking(ctx context.Context, request BookingRequest) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, http.MethodPost, "/api/v1/bookings", request, idempotencyKey(ctx), &booking)
+	return booking, err
+}
+
+func (c *Client) GetBooking(ctx context.Context, id uuid.UUID) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, http.MethodGet, "/api/v1/bookings/"+id.String(), nil, "", &booking)
+	return booking, err
+}
+
+// UpdateBooking replaces details of booking, empty strings clear the values
+func (c *Client) UpdateBooking(ctx context.Context, id uuid.UUID, details BookingDetails) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, http.MethodPatch, "/api/v1/bookings/"+id.String(), details, "", &booking)
+	return booking, err
+}
+
+// ConfirmBooking confirms reserved booking, its units get tickets
+func (c *Client) ConfirmBooking(ctx context.Context, id uuid.UUID) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, http.MethodPost, "/api/v1/bookings/"+id.String()+"/confirm", nil, idempotencyKey(ctx), &booking)
+	return booking, err
+}
+
+// do sends request and decodes response into result, every call is either safe to repeat or sent with
+// idempotencyKey, so failed attempts are retried
+func (c *Client) do(ctx context.Context, method string, path string, body any, idempotencyKey string, result any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request failed: %w", err)
+		}
+	}
+	// retries share correlation ID, so that they can be found together
+	correlationID := pkg.GetCorrelationIDCtx(ctx)
+	if correlationID == uuid.Nil {
+		correlationID = uuid.New()
+	}
+
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.send(ctx, method, path, payload, correlationID, idempotencyKey, result)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retries || !retryable(ctx, err) {
+			return err
+		}
+		wait := max(delay, retryAfter)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// send sends single attempt of the request, retryAfter is set when server asked for it
+func (c *Client) send(ctx context.Context, method string, path string, payload []byte, correlationID uuid.UUID, idempotencyKey string, result any) (time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, fmt.Errorf("creating request failed: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("x-correlation-id", correlationID.String())
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.capability != "" {
+		request.Header.Set("Capability", c.capability)
+	}
+	if idempotencyKey != "" {
+		request.Header.Set(pkg.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode >= 300 {
+		return parseRetryAfter(response.Header.Get("Retry-After")), decodeProblem(response)
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return 0, nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return 0, fmt.Errorf("decoding response of %s %s failed: %w", method, path, err)
+	}
+	return 0, nil
+}
+
+// decodeProblem reads problem detail of failed response, responses of proxies without problem detail
+// are described by their status
+func decodeProblem(response *http.Response) error {
+	problem := &ProblemError{}
+	body, err := io.ReadAll(response.Body)
+	if err == nil && strings.HasPrefix(response.Header.Get("Content-Type"), "application/problem+json") {
+		err = json.Unmarshal(body, &problem.ValidationProblemDetail)
+	}
+	if err != nil || problem.Status == 0 {
+		problem.Status = response.StatusCode
+		problem.Title = http.StatusText(response.StatusCode)
+		problem.Detail = strings.TrimSpace(string(body))
+	}
+	return problem
+}
+
+// retryable reports errors that might not repeat: network errors, overload of the server and requests
+// with the same idempotency key still in progress
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		return true
+	}
+	switch problem.Status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return problem.Code == pkg.ProblemCodeIdempotencyKeyInUse
+	default:
+		return false
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
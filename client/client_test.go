@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/internal"
+	"github.com/prathoss/hw/internal/testdb"
+	"github.com/prathoss/hw/pkg"
+)
+
+func TestClient_RetriesWithSameIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	keys := make([]string, 0, 3)
+	correlationIDs := make([]string, 0, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get(pkg.IdempotencyKeyHeader))
+		correlationIDs = append(correlationIDs, r.Header.Get("x-correlation-id"))
+		if len(keys) < 3 {
+			pkg.WriteError(w, r, pkg.NewServiceUnavailableError(errors.New("database is down")))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Booking{ID: uuid.New(), Status: BookingStatusReserved})
+	}))
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	correlationID := uuid.New()
+
+	_, err = c.CreateBooking(pkg.SetCorrelationID(context.Background(), correlationID), BookingRequest{Units: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(keys))
+	}
+	for i := range keys {
+		if keys[i] == "" || keys[i] != keys[0] {
+			t.Fatalf("expected every attempt to have the same idempotency key, got %v", keys)
+		}
+		if correlationIDs[i] != correlationID.String() {
+			t.Fatalf("expected correlation ID %s to be propagated, got %v", correlationID, correlationIDs)
+		}
+	}
+}
+
+func TestClient_DecodesProblems(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		pkg.WriteError(w, r, pkg.NewDomainError(pkg.ErrConflict, "AVAILABILITY_SOLD_OUT", "sold out"))
+	}))
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.ConfirmBooking(context.Background(), uuid.New())
+
+	var problem *ProblemError
+	if !errors.As(err, &problem) {
+		t.Fatalf("expected problem error, got %v", err)
+	}
+	if !errors.Is(err, pkg.ErrConflict) || !HasCode(err, "AVAILABILITY_SOLD_OUT") {
+		t.Fatalf("expected sold out conflict, got %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("expected conflict not to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestClient_Server(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	productID := uuid.New()
+	availabilityID := uuid.New()
+	today := NewDate(time.Now().UTC())
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 2)", productID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.pricing(product_id, price, currency) VALUES ($1, 1000, 'EUR')", productID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.availability(id, product_id, date) VALUES ($1, $2, $3)", availabilityID, productID, today.Time()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := internal.DefaultConfig()
+	cfg.Database.DSN = pgConn
+	cfg.Server.ValidateResponses = true
+	s, err := internal.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	handler, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, WithCapability(CapabilityPricing))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product, err := c.GetProduct(ctx, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Price != 1000 || product.Currency != "EUR" {
+		t.Fatalf("expected product with price, got %+v", product)
+	}
+
+	availabilities, err := c.GetAvailability(ctx, productID, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(availabilities) != 1 || availabilities[0].Vacancies != 2 {
+		t.Fatalf("expected single availability with 2 vacancies, got %+v", availabilities)
+	}
+
+	request := BookingRequest{ProductID: productID, AvailabilityID: availabilityID, Units: 1}
+	keyCtx := WithIdempotencyKey(ctx, uuid.NewString())
+	booking, err := c.CreateBooking(keyCtx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := c.CreateBooking(keyCtx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ID != booking.ID {
+		t.Fatalf("expected retry with the same key to return booking %s, got %s", booking.ID, replayed.ID)
+	}
+	availabilities, err = c.GetAvailability(ctx, productID, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if availabilities[0].Vacancies != 1 {
+		t.Fatalf("expected booking to be created once, got %d vacancies", availabilities[0].Vacancies)
+	}
+
+	confirmed, err := c.ConfirmBooking(ctx, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Status != BookingStatusConfirmed || confirmed.Units[0].Ticket == nil {
+		t.Fatalf("expected confirmed booking with tickets, got %+v", confirmed)
+	}
+
+	correlationID := uuid.New()
+	_, err = c.ConfirmBooking(pkg.SetCorrelationID(ctx, correlationID), booking.ID)
+	var problem *ProblemError
+	if !errors.As(err, &problem) || problem.Code != "BOOKING_ALREADY_CONFIRMED" {
+		t.Fatalf("expected booking already confirmed problem, got %v", err)
+	}
+	if problem.CorrelationID != correlationID {
+		t.Fatalf("expected correlation ID %s, got %s", correlationID, problem.CorrelationID)
+	}
+
+	_, err = c.GetProduct(ctx, uuid.New())
+	if !errors.Is(err, pkg.ErrNotFound) || !HasCode(err, "PRODUCT_NOT_FOUND") {
+		t.Fatalf("expected product not found, got %v", err)
+	}
+}
+
+func setupPgAndMigrations() (string, func(), error) {
+	return testdb.Setup(func(ctx context.Context, dsn string) error {
+		migrator, err := internal.NewMigrator(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = migrator.Close()
+		}()
+		return migrator.Up()
+	})
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prathoss/hw/pkg"
+)
+
+var _ error = &ProblemError{}
+
+// ProblemError is problem detail returned by the server. It matches kinds of pkg domain errors with errors.Is,
+// e.g. errors.Is(err, pkg.ErrNotFound), use Code to branch on the specific problem.
+type ProblemError struct {
+	pkg.ValidationProblemDetail
+}
+
+func (p *ProblemError) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", p.Status, p.Code, p.Detail)
+	}
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Code, p.Title)
+}
+
+func (p *ProblemError) Is(target error) bool {
+	switch p.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == pkg.ErrValidation
+	case http.StatusNotFound:
+		return target == pkg.ErrNotFound
+	case http.StatusConflict:
+		return target == pkg.ErrConflict
+	case http.StatusGone:
+		return target == pkg.ErrGone
+	default:
+		return false
+	}
+}
+
+// HasCode reports whether err is a problem with code
+func HasCode(err error, code string) bool {
+	var problem *ProblemError
+	return errors.As(err, &problem) && problem.Code == code
+}
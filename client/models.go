@@ -0,0 +1,129 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const dateFormat = "2006-01-02"
+
+// Date is a day without time zone, it is sent as yyyy-MM-dd
+type Date time.Time
+
+// NewDate returns day of t
+func NewDate(t time.Time) Date {
+	return Date(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+func (d Date) Time() time.Time {
+	return time.Time(d)
+}
+
+func (d Date) String() string {
+	return time.Time(d).Format(dateFormat)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(bytes []byte) error {
+	var value string
+	if err := json.Unmarshal(bytes, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return err
+	}
+	*d = Date(parsed)
+	return nil
+}
+
+// Pricing is returned with CapabilityPricing, it is empty otherwise
+type Pricing struct {
+	// Price of single unit in minor units of Currency, 1000 represents 10.0 EUR
+	Price    int    `json:"price,omitempty"`
+	Currency string `json:"currency,omitempty"`
+}
+
+type Product struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Capacity int       `json:"capacity"`
+	// HorizonDays is how many days ahead of today the product can be booked
+	HorizonDays int `json:"horizonDays"`
+	// LeadTimeMinutes is how long before the start of the booked day the bookings close, without lead time
+	// the day can be booked until its end
+	LeadTimeMinutes int `json:"leadTimeMinutes"`
+	// SalesCutoff is the last day product can be booked for, nil when sales do not end
+	SalesCutoff *Date `json:"salesCutoff"`
+	Pricing
+}
+
+const (
+	AvailabilityStatusAvailable = "AVAILABLE"
+	AvailabilityStatusSoldOut   = "SOLD_OUT"
+)
+
+type Availability struct {
+	ID        uuid.UUID `json:"id"`
+	LocalDate Date      `json:"localDate"`
+	Status    string    `json:"status"`
+	Vacancies int       `json:"vacancies"`
+	Available bool      `json:"available"`
+	Pricing
+}
+
+// ProductAvailabilities are availabilities of one product
+type ProductAvailabilities struct {
+	ProductID      uuid.UUID      `json:"productId"`
+	Availabilities []Availability `json:"availabilities"`
+}
+
+const (
+	BookingStatusReserved  = "RESERVED"
+	BookingStatusConfirmed = "CONFIRMED"
+)
+
+type Booking struct {
+	ID             uuid.UUID `json:"id"`
+	Status         string    `json:"status"`
+	ProductID      uuid.UUID `json:"productId"`
+	AvailabilityID uuid.UUID `json:"availabilityId"`
+	Units          []Unit    `json:"units"`
+	BookingDetails
+	Pricing
+}
+
+// BookingDetails are optional customer information of booking
+type BookingDetails struct {
+	Contact           Contact `json:"contact"`
+	Notes             string  `json:"notes"`
+	ResellerReference string  `json:"resellerReference"`
+}
+
+type Contact struct {
+	FullName     string `json:"fullName"`
+	EmailAddress string `json:"emailAddress"`
+	// PhoneNumber is in E.164 format, e.g. +420123456789
+	PhoneNumber string `json:"phoneNumber"`
+	Locale      string `json:"locale"`
+	Country     string `json:"country"`
+}
+
+type Unit struct {
+	ID uuid.UUID `json:"id"`
+	// Ticket is set once the booking is confirmed
+	Ticket *string `json:"ticket"`
+	Pricing
+}
+
+type BookingRequest struct {
+	ProductID      uuid.UUID `json:"productId"`
+	AvailabilityID uuid.UUID `json:"availabilityId"`
+	Units          int       `json:"units"`
+	BookingDetails
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/internal"
+	"github.com/prathoss/hw/pkg"
+	"github.com/spf13/cobra"
+)
+
+const dateFormat = "2006-01-02"
+
+var (
+	backfillProductIDs []string
+	backfillFrom       string
+	backfillTo         string
+	backfillDryRun     bool
+	reconcileFix       bool
+)
+
+// availabilityCmd represents the availability command
+var availabilityCmd = &cobra.Command{
+	Use:   "availability",
+	Short: "Manages availabilities",
+}
+
+var availabilityBackfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Generates missing availabilities of products in the date range",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := slog.With("component", "availability-backfill")
+		params, err := backfillParams()
+		if err != nil {
+			logger.Error("invalid arguments", pkg.Err(err))
+			return err
+		}
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			logger.Error("could not initialize config", pkg.Err(err))
+			return err
+		}
+		pkg.SetupLogger(cfg.Logging)
+		s, err := internal.NewServer(cfg)
+		if err != nil {
+			logger.Error("creating server failed", pkg.Err(err))
+			return err
+		}
+		defer s.Close()
+
+		result, err := s.GenerateAvailabilities(cmd.Context(), internal.JobBackfillAvailabilities, params)
+		if err != nil {
+			logger.Error("generating availabilities failed", pkg.Err(err))
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		if result.DryRun {
+			_, _ = fmt.Fprintln(w, "PRODUCT\tDATE")
+			for _, availability := range result.Availabilities {
+				_, _ = fmt.Fprintf(w, "%s\t%s\n", availability.ProductID, time.Time(availability.LocalDate).Format(dateFormat))
+			}
+			_, _ = fmt.Fprintf(w, "%d availabilities would be inserted\n", len(result.Availabilities))
+			return w.Flush()
+		}
+
+		_, _ = fmt.Fprintln(w, "PRODUCT\tLATEST DATE\tPLANNED\tINSERTED\tERROR")
+		for _, product := range result.Products {
+			latestDate := "-"
+			if product.LatestDate != nil {
+				latestDate = time.Time(*product.LatestDate).Format(dateFormat)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", product.ProductID, latestDate, product.Planned, product.Inserted, product.Error)
+		}
+		_, _ = fmt.Fprintf(w, "%d availabilities inserted, %d products failed\n", result.Inserted, result.Failed)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if result.Failed > 0 {
+			return fmt.Errorf("generating availabilities failed for %d products", result.Failed)
+		}
+		return nil
+	},
+}
+
+var availabilityReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Verifies booked counters of availabilities against their tickets",
+	Long: `Verifies booked counters of availabilities against their tickets, every mismatch is printed.
+Command fails when there is a mismatch, unless --fix is set, then counters are set to number of tickets.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := slog.With("component", "availability-reconcile")
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			logger.Error("could not initialize config", pkg.Err(err))
+			return err
+		}
+		pkg.SetupLogger(cfg.Logging)
+		s, err := internal.NewServer(cfg)
+		if err != nil {
+			logger.Error("creating server failed", pkg.Err(err))
+			return err
+		}
+		defer s.Close()
+
+		mismatches, err := s.ReconcileBookedCounters(cmd.Context(), reconcileFix)
+		if err != nil {
+			logger.Error("reconciling booked counters failed", pkg.Err(err))
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "AVAILABILITY\tPRODUCT\tDATE\tBOOKED\tTICKETS")
+		for _, mismatch := range mismatches {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", mismatch.AvailabilityID, mismatch.ProductID, time.Time(mismatch.LocalDate).Format(dateFormat), mismatch.Booked, mismatch.Tickets)
+		}
+		if reconcileFix {
+			_, _ = fmt.Fprintf(w, "%d booked counters fixed\n", len(mismatches))
+		} else {
+			_, _ = fmt.Fprintf(w, "%d booked counters differ from tickets\n", len(mismatches))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !reconcileFix && len(mismatches) > 0 {
+			return fmt.Errorf("%d booked counters differ from tickets", len(mismatches))
+		}
+		return nil
+	},
+}
+
+func backfillParams() (internal.AvailabilityGenerationParams, error) {
+	errs := make([]error, 0, 3)
+	productIDs := make([]uuid.UUID, 0, len(backfillProductIDs))
+	for _, productIDStr := range backfillProductIDs {
+		productID, err := uuid.Parse(productIDStr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s is malformed: %w", productIDStr, err))
+			continue
+		}
+		productIDs = append(productIDs, productID)
+	}
+	from, err := time.Parse(dateFormat, backfillFrom)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("from must be in format %s: %w", dateFormat, err))
+	}
+	to, err := time.Parse(dateFormat, backfillTo)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("to must be in format %s: %w", dateFormat, err))
+	}
+	if to.Before(from) {
+		errs = append(errs, errors.New("to must not be before from"))
+	}
+	return internal.AvailabilityGenerationParams{
+		ProductIDs: productIDs,
+		From:       internal.JSONTime(from),
+		To:         internal.JSONTime(to),
+		DryRun:     backfillDryRun,
+	}, errors.Join(errs...)
+}
+
+func init() {
+	availabilityBackfillCmd.Flags().StringSliceVar(&backfillProductIDs, "product", nil, "ID of product to generate, can be repeated, all products are generated when omitted")
+	availabilityBackfillCmd.Flags().StringVar(&backfillFrom, "from", "", fmt.Sprintf("first generated day (%s)", dateFormat))
+	availabilityBackfillCmd.Flags().StringVar(&backfillTo, "to", "", fmt.Sprintf("last generated day (%s)", dateFormat))
+	availabilityBackfillCmd.Flags().BoolVar(&backfillDryRun, "dry-run", false, "only print availabilities that would be inserted")
+	_ = availabilityBackfillCmd.MarkFlagRequired("from")
+	_ = availabilityBackfillCmd.MarkFlagRequired("to")
+	availabilityCmd.AddCommand(availabilityBackfillCmd)
+	availabilityReconcileCmd.Flags().BoolVar(&reconcileFix, "fix", false, "set booked counters to number of tickets")
+	availabilityCmd.AddCommand(availabilityReconcileCmd)
+	rootCmd.AddCommand(availabilityCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/prathoss/hw/internal"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configPath    string
+	serverAddress string
+	databaseDSN   string
+	logLevel      string
+	logFormat     string
+)
+
+// configFlags override config with flags of the same name
+var configFlags = map[string]func(cfg *internal.Config) error{
+	"server-address": func(cfg *internal.Config) error {
+		cfg.Server.Address = serverAddress
+		return nil
+	},
+	"database": func(cfg *internal.Config) error {
+		cfg.Database.DSN = databaseDSN
+		return nil
+	},
+	"log-level": func(cfg *internal.Config) error {
+		if err := cfg.Logging.Level.UnmarshalText([]byte(logLevel)); err != nil {
+			return fmt.Errorf("--log-level is invalid: %w", err)
+		}
+		return nil
+	},
+	"log-format": func(cfg *internal.Config) error {
+		cfg.Logging.Format = logFormat
+		return nil
+	},
+}
+
+// loadConfig loads config from file, environment and flags that were set on command line
+func loadConfig(cmd *cobra.Command) (internal.Config, error) {
+	overrides := make([]func(cfg *internal.Config) error, 0, len(configFlags))
+	for name, override := range configFlags {
+		if cmd.Flags().Changed(name) {
+			overrides = append(overrides, override)
+		}
+	}
+	return internal.LoadConfig(configPath, overrides...)
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Prints effective configuration with secrets masked",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent(2)
+		if err := encoder.Encode(cfg.Masked()); err != nil {
+			return err
+		}
+		return encoder.Close()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", os.Getenv("HW_CONFIG"), "path to YAML config file, defaults to HW_CONFIG")
+	rootCmd.PersistentFlags().StringVar(&serverAddress, "server-address", "", "address server listens on, overrides server.address")
+	rootCmd.PersistentFlags().StringVar(&databaseDSN, "database", "", "database connection string, overrides database.dsn")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "log level, overrides logging.level")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "log format, overrides logging.format")
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
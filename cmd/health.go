@@ -2,35 +2,50 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 
-	"github.com/prathoss/hw/internal"
 	"github.com/prathoss/hw/pkg"
 	"github.com/spf13/cobra"
 )
 
+const (
+	probeLiveness  = "live"
+	probeReadiness = "ready"
+)
+
+var healthProbe string
+
 // healthCmd represents the health command
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "Checks health of server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := internal.NewConfigFromEnv()
+		cfg, err := loadConfig(cmd)
 		logger := slog.With("component", "health")
 		if err != nil {
 			logger.Error("could not initialize config", pkg.Err(err))
 			return err
 		}
+		pkg.SetupLogger(cfg.Logging)
+		if healthProbe != probeLiveness && healthProbe != probeReadiness {
+			err := fmt.Errorf("probe must be one of %s, %s", probeLiveness, probeReadiness)
+			logger.Error("invalid probe", pkg.Err(err))
+			return err
+		}
 		client := http.DefaultClient
-		resp, err := client.Get(fmt.Sprintf("http://%s/api/v1/health", cfg.ServerAddress))
+		resp, err := client.Get(fmt.Sprintf("http://%s/api/v1/health/%s", cfg.Server.Address, healthProbe))
 		if err != nil {
 			logger.Error("could not connect to server", pkg.Err(err))
 			return err
 		}
+		defer resp.Body.Close()
 
 		if resp.StatusCode > 299 {
+			body, _ := io.ReadAll(resp.Body)
 			err := fmt.Errorf("server returned not successful status code %s", resp.Status)
-			logger.Error("did not receive successful status code", pkg.Err(err))
+			logger.Error("did not receive successful status code", pkg.Err(err), slog.String("body", string(body)))
 			return err
 		}
 		return nil
@@ -38,5 +53,6 @@ var healthCmd = &cobra.Command{
 }
 
 func init() {
+	healthCmd.Flags().StringVar(&healthProbe, "probe", probeReadiness, fmt.Sprintf("probe to check, one of %s, %s", probeLiveness, probeReadiness))
 	rootCmd.AddCommand(healthCmd)
 }
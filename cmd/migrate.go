@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/prathoss/hw/internal"
+	"github.com/prathoss/hw/pkg"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manages database migrations embedded in the binary",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Applies all pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withMigrator(cmd, func(migrator *internal.Migrator) error {
+			return migrator.UpLocked(cmd.Context())
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [steps]",
+	Short: "Reverts last applied migrations, one by default",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps := 1
+		if len(args) > 0 {
+			parsed, err := strconv.Atoi(args[0])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("steps must be positive number, got %s", args[0])
+			}
+			steps = parsed
+		}
+		return withMigrator(cmd, func(migrator *internal.Migrator) error {
+			return migrator.Down(steps)
+		})
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Lists embedded migrations and whether they are applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withMigrator(cmd, func(migrator *internal.Migrator) error {
+			statuses, err := migrator.Status()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+			for _, status := range statuses {
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%t\n", status.Version, status.Name, status.Applied)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var migrateVersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Prints version of the last applied migration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withMigrator(cmd, func(migrator *internal.Migrator) error {
+			version, dirty, err := migrator.Version()
+			if err != nil {
+				return err
+			}
+			if dirty {
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d (dirty)\n", version)
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), version)
+			return err
+		})
+	},
+}
+
+func withMigrator(cmd *cobra.Command, fn func(migrator *internal.Migrator) error) error {
+	logger := slog.With("component", "migrate")
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Error("could not initialize config", pkg.Err(err))
+		return err
+	}
+	pkg.SetupLogger(cfg.Logging)
+	migrator, err := internal.NewMigrator(cmd.Context(), cfg.Database.DSN)
+	if err != nil {
+		logger.Error("could not create migrator", pkg.Err(err))
+		return err
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			logger.Error("could not close migrator", pkg.Err(err))
+		}
+	}()
+	if err := fn(migrator); err != nil {
+		logger.Error("migration command failed", pkg.Err(err))
+		return err
+	}
+	return nil
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateVersionCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
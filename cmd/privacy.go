@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prathoss/hw/internal"
+	"github.com/prathoss/hw/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	privacyEmailAddress string
+	privacyAuditsLimit  int
+)
+
+// privacyCmd represents the privacy command
+var privacyCmd = &cobra.Command{
+	Use:   "privacy",
+	Short: "Handles data-subject requests of booking contacts",
+}
+
+var privacyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Prints all bookings and tickets of the email address as JSON",
+	Long: `Prints all bookings and tickets whose contact has the email address as JSON.
+Export is recorded in the privacy audit trail.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := slog.With("component", "privacy-export")
+		s, err := newPrivacyServer(cmd, logger)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		export, err := s.ExportDataSubject(cmd.Context(), privacyEmailAddress, internal.PrivacySourceCLI)
+		if err != nil {
+			logger.Error("exporting bookings failed", pkg.Err(err))
+			return err
+		}
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	},
+}
+
+var privacyEraseCmd = &cobra.Command{
+	Use:   "erase",
+	Short: "Anonymises contacts of bookings of the email address",
+	Long: `Anonymises contact and notes of all bookings whose contact has the email address.
+Bookings, tickets, pricing and reseller references are kept for accounting. Erasure is recorded in the privacy audit trail.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := slog.With("component", "privacy-erase")
+		s, err := newPrivacyServer(cmd, logger)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		erasure, err := s.EraseDataSubject(cmd.Context(), privacyEmailAddress, internal.PrivacySourceCLI)
+		if err != nil {
+			logger.Error("erasing contacts failed", pkg.Err(err))
+			return err
+		}
+		w := cmd.OutOrStdout()
+		for _, bookingID := range erasure.BookingIDs {
+			_, _ = fmt.Fprintln(w, bookingID)
+		}
+		_, _ = fmt.Fprintf(w, "%d booking contacts anonymised\n", len(erasure.BookingIDs))
+		return nil
+	},
+}
+
+var privacyAuditsCmd = &cobra.Command{
+	Use:   "audits",
+	Short: "Prints latest records of the privacy audit trail",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := slog.With("component", "privacy-audits")
+		s, err := newPrivacyServer(cmd, logger)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		audits, err := s.ListPrivacyAudits(cmd.Context(), privacyAuditsLimit)
+		if err != nil {
+			logger.Error("listing privacy audits failed", pkg.Err(err))
+			return err
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CREATED AT\tACTION\tSOURCE\tBOOKINGS\tSUBJECT")
+		for _, audit := range audits {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", audit.CreatedAt.Format(time.RFC3339), audit.Action, audit.Source, audit.Bookings, audit.Subject)
+		}
+		return w.Flush()
+	},
+}
+
+func newPrivacyServer(cmd *cobra.Command, logger *slog.Logger) (*internal.Server, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Error("could not initialize config", pkg.Err(err))
+		return nil, err
+	}
+	pkg.SetupLogger(cfg.Logging)
+	s, err := internal.NewServer(cfg)
+	if err != nil {
+		logger.Error("creating server failed", pkg.Err(err))
+		return nil, err
+	}
+	return s, nil
+}
+
+func init() {
+	for _, command := range []*cobra.Command{privacyExportCmd, privacyEraseCmd} {
+		command.Flags().StringVar(&privacyEmailAddress, "email", "", "email address of booking contact")
+		_ = command.MarkFlagRequired("email")
+		privacyCmd.AddCommand(command)
+	}
+	privacyAuditsCmd.Flags().IntVar(&privacyAuditsLimit, "limit", 50, "number of latest records to print")
+	privacyCmd.AddCommand(privacyAuditsCmd)
+	rootCmd.AddCommand(privacyCmd)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
 	"os"
 
@@ -9,16 +10,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var migrateOnStart bool
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "hw",
 	Short: "Product, availability, booking server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := internal.NewConfigFromEnv()
+		cfg, err := loadConfig(cmd)
 		if err != nil {
 			slog.Error("loading config failed", pkg.Err(err))
 			return err
 		}
+		pkg.SetupLogger(cfg.Logging)
+		pkg.ToggleDebugOnSignal(cmd.Context())
+		shutdownTracing, err := pkg.SetupTracing(cmd.Context(), cfg.Tracing)
+		if err != nil {
+			slog.Error("setting up tracing failed", pkg.Err(err))
+			return err
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				slog.Error("shutting down tracing failed", pkg.Err(err))
+			}
+		}()
+		if migrateOnStart {
+			if err := migrateUp(cmd.Context(), cfg); err != nil {
+				slog.Error("migrating database failed", pkg.Err(err))
+				return err
+			}
+		}
 		s, err := internal.NewServer(cfg)
 		if err != nil {
 			slog.Error("creating server failed", pkg.Err(err))
@@ -40,3 +61,20 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func migrateUp(ctx context.Context, cfg internal.Config) error {
+	migrator, err := internal.NewMigrator(ctx, cfg.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			slog.Error("could not close migrator", pkg.Err(err))
+		}
+	}()
+	return migrator.UpLocked(ctx)
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&migrateOnStart, "migrate-on-start", false, "apply pending migrations before the server starts")
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/prathoss/hw/pkg"
+)
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) getLogLevel(_ http.ResponseWriter, _ *http.Request) (any, error) {
+	return LogLevel{Level: pkg.LogLevel().String()}, nil
+}
+
+func (s *Server) setLogLevel(_ http.ResponseWriter, r *http.Request) (any, error) {
+	var request LogLevel
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, pkg.NewBadRequestError(pkg.InvalidParam{
+			Name:   "Body",
+			Reason: err.Error(),
+		})
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(request.Level)); err != nil {
+		return nil, pkg.NewBadRequestError(pkg.InvalidParam{
+			Name:   "level",
+			Reason: "Must be one of DEBUG, INFO, WARN, ERROR",
+		})
+	}
+	pkg.SetLogLevel(level)
+	return LogLevel{Level: level.String()}, nil
+}
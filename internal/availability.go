@@ -2,7 +2,12 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,15 +26,179 @@ type Availability struct {
 	ProductID uuid.UUID `json:"-"`
 }
 
-type AvailabilityDayRequest struct {
+// AvailabilityRequest is body of availability request, it asks either for the day localDate or for the range
+// between localDateStart and localDateEnd of one product, of productIds or of all products
+type AvailabilityRequest struct {
 	ProductId uuid.UUID `json:"productId"`
-	LocalDate JSONTime  `json:"localDate"`
+	// ProductIds and AllProducts ask for availabilities grouped by product
+	ProductIds     []uuid.UUID `json:"productIds,omitempty"`
+	AllProducts    bool        `json:"allProducts,omitempty"`
+	LocalDate      *JSONTime   `json:"localDate,omitempty"`
+	LocalDateStart *JSONTime   `json:"localDateStart,omitempty"`
+	LocalDateEnd   *JSONTime   `json:"localDateEnd,omitempty"`
+}
+
+// grouped reports whether request asks for more products, their availabilities are grouped by product
+func (a AvailabilityRequest) grouped() bool {
+	return len(a.ProductIds) > 0 || a.AllProducts
+}
+
+// ProductAvailabilities are availabilities of one product, T is Availability or PricedAvailability
+type ProductAvailabilities[T any] struct {
+	ProductID      uuid.UUID `json:"productId"`
+	Availabilities []T       `json:"availabilities"`
+}
+
+// groupAvailabilities groups availabilities by products in order of products, product without availabilities has
+// empty group
+func groupAvailabilities[T any](products []Product, availabilities []T, productID func(T) uuid.UUID) []ProductAvailabilities[T] {
+	groups := make([]ProductAvailabilities[T], 0, len(products))
+	indexes := make(map[uuid.UUID]int, len(products))
+	for i, product := range products {
+		indexes[product.ID] = i
+		groups = append(groups, ProductAvailabilities[T]{
+			ProductID:      product.ID,
+			Availabilities: make([]T, 0),
+		})
+	}
+	for _, availability := range availabilities {
+		if i, ok := indexes[productID(availability)]; ok {
+			groups[i].Availabilities = append(groups[i].Availabilities, availability)
+		}
+	}
+	return groups
+}
+
+const (
+	// maxAvailabilityRangeDays limits span of requested availability to a whole year
+	maxAvailabilityRangeDays = 366
+	// maxAvailabilityProductDays limits products times days of one request, e.g. 50 products for the longest range
+	maxAvailabilityProductDays = 50 * (maxAvailabilityRangeDays + 1)
+)
+
+// decodeAvailabilityRequest strictly decodes availability request and returns its range, request of one day has
+// the same start and end. Request is a range request when it has localDateStart or localDateEnd, fields of both
+// variants or unknown fields are rejected, exactly one of productId, productIds and allProducts must be set.
+// All problems of the request are returned together.
+func decodeAvailabilityRequest(body io.Reader) (AvailabilityRequest, time.Time, time.Time, []pkg.InvalidParam) {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	var request AvailabilityRequest
+	if err := decoder.Decode(&request); err != nil {
+		return AvailabilityRequest{}, time.Time{}, time.Time{}, []pkg.InvalidParam{
+			{
+				Name:   "Body",
+				Reason: err.Error(),
+			},
+		}
+	}
+
+	invalidParams := make([]pkg.InvalidParam, 0, 3)
+	selections := 0
+	for _, selected := range []bool{request.ProductId != uuid.Nil, len(request.ProductIds) > 0, request.AllProducts} {
+		if selected {
+			selections++
+		}
+	}
+	switch {
+	case selections == 0:
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "productId",
+			Reason: "Either productId, productIds or allProducts must be set",
+		})
+	case selections > 1:
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "productIds",
+			Reason: "Only one of productId, productIds and allProducts can be set",
+		})
+	case slices.Contains(request.ProductIds, uuid.Nil):
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "productIds",
+			Reason: "Must not contain empty ID",
+		})
+	}
+	request.ProductIds = uniqueProductIDs(request.ProductIds, func(id uuid.UUID) uuid.UUID { return id })
+	var from, to time.Time
+	isRange := request.LocalDateStart != nil || request.LocalDateEnd != nil
+	switch {
+	case isRange && request.LocalDate != nil:
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "localDate",
+			Reason: "Must not be combined with localDateStart and localDateEnd",
+		})
+	case isRange:
+		if request.LocalDateStart == nil {
+			invalidParams = append(invalidParams, pkg.InvalidParam{
+				Name:   "localDateStart",
+				Reason: "Must be set together with localDateEnd",
+			})
+		}
+		if request.LocalDateEnd == nil {
+			invalidParams = append(invalidParams, pkg.InvalidParam{
+				Name:   "localDateEnd",
+				Reason: "Must be set together with localDateStart",
+			})
+		}
+		if request.LocalDateStart != nil && request.LocalDateEnd != nil {
+			from = time.Time(*request.LocalDateStart).UTC()
+			to = time.Time(*request.LocalDateEnd).UTC()
+			invalidParams = append(invalidParams, validateDateRange("localDateStart", "localDateEnd", from, to)...)
+		}
+	case request.LocalDate == nil:
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "localDate",
+			Reason: "Either localDate or localDateStart and localDateEnd must be set",
+		})
+	default:
+		from = time.Time(*request.LocalDate).UTC()
+		to = from
+	}
+	if len(invalidParams) == 0 {
+		invalidParams = append(invalidParams, validateProductDays("productIds", len(request.ProductIds), from, to)...)
+	}
+	return request, from, to, invalidParams
+}
+
+// validateProductDays checks that availabilities of products between from and to are at most maxAvailabilityProductDays
+func validateProductDays(name string, products int, from time.Time, to time.Time) []pkg.InvalidParam {
+	days := int(to.Sub(from).Hours()/24) + 1
+	if products*days <= maxAvailabilityProductDays {
+		return nil
+	}
+	return []pkg.InvalidParam{
+		{
+			Name:   name,
+			Reason: fmt.Sprintf("Products times days of the range must be at most %d, shorten the range or request less products", maxAvailabilityProductDays),
+		},
+	}
+}
+
+// validateDateRange checks that to is not before from and the range is at most maxAvailabilityRangeDays long
+func validateDateRange(fromName string, toName string, from time.Time, to time.Time) []pkg.InvalidParam {
+	if to.Before(from) {
+		return []pkg.InvalidParam{
+			{
+				Name:   toName,
+				Reason: fmt.Sprintf("Must not be before %s", fromName),
+			},
+		}
+	}
+	if to.Sub(from) > maxAvailabilityRangeDays*24*time.Hour {
+		return []pkg.InvalidParam{
+			{
+				Name:   toName,
+				Reason: fmt.Sprintf("Must be at most %d days after %s", maxAvailabilityRangeDays, fromName),
+			},
+		}
+	}
+	return nil
 }
 
-type AvailabilityRangeRequest struct {
-	ProductId      uuid.UUID `json:"productId"`
-	LocalDateStart JSONTime  `json:"localDateStart"`
-	LocalDateEnd   JSONTime  `json:"localDateEnd"`
+type AvailabilityCoverage struct {
+	// LatestDate is the last day product has availability for, nil when product has none
+	LatestDate *time.Time
+	// Dates are days with availability within the queried range
+	Dates []time.Time
 }
 
 const (
@@ -37,15 +206,47 @@ const (
 	AvailabilityStatusSoldOut   = "SOLD_OUT"
 )
 
+// BookedCounterMismatch is availability whose booked counter differs from number of its tickets
+type BookedCounterMismatch struct {
+	AvailabilityID uuid.UUID `json:"availabilityId"`
+	ProductID      uuid.UUID `json:"productId"`
+	LocalDate      JSONTime  `json:"localDate"`
+	Booked         int       `json:"booked"`
+	Tickets        int       `json:"tickets"`
+}
+
 type AvailabilityProcessor interface {
-	InsertAvailabilities(ctx context.Context, availabilities []Availability) error
+	// InsertAvailabilities inserts availabilities skipping days that already exist, it returns number of inserted rows
+	InsertAvailabilities(ctx context.Context, availabilities []Availability) (int64, error)
 	GetAvailability(ctx context.Context, productID uuid.UUID, day time.Time) ([]Availability, error)
 	GetAvailabilityTo(ctx context.Context, productID uuid.UUID, from time.Time, to time.Time) ([]Availability, error)
+	// GetProductsAvailabilityTo returns availabilities of all productIDs between from and to ordered by date
+	GetProductsAvailabilityTo(ctx context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) ([]Availability, error)
 	GetAvailabilityByID(ctx context.Context, id uuid.UUID) (Availability, error)
-	GetLatestAvailability(ctx context.Context, productID uuid.UUID) (*Availability, error)
+	// GetAvailabilityCalendar returns days of product between from and to, days are priced in currency unless it is empty
+	GetAvailabilityCalendar(ctx context.Context, productID uuid.UUID, from time.Time, to time.Time, currency string) ([]CalendarDay, error)
+	// GetAvailabilityCoverage returns for each of products its latest availability day and days existing between from and to
+	GetAvailabilityCoverage(ctx context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) (map[uuid.UUID]AvailabilityCoverage, error)
+	// GetProductsNotCovered returns IDs of products whose availability on today does not reach the end of their
+	// sales window shortened by slackDays
+	GetProductsNotCovered(ctx context.Context, today time.Time, slackDays int) ([]uuid.UUID, error)
+	// ListenAvailabilityChanges blocks and calls notify with ID of every availability whose bookings changed,
+	// it returns when ctx is done or listening fails
+	ListenAvailabilityChanges(ctx context.Context, notify func(availabilityID uuid.UUID)) error
+}
+
+// BookedCounterReconciler is implemented by availability processors keeping booked units in a counter,
+// processors counting booked units from bookings have nothing to reconcile
+type BookedCounterReconciler interface {
+	// ReconcileBookedCounters returns availabilities whose booked counter does not match their tickets,
+	// with fix the counters are set to number of tickets
+	ReconcileBookedCounters(ctx context.Context, fix bool) ([]BookedCounterMismatch, error)
 }
 
 var _ AvailabilityProcessor = &AvailabilityRepository{}
+var _ BookedCounterReconciler = &AvailabilityRepository{}
+
+var errBookedCountersNotKept = errors.New("availability processor does not keep booked counters")
 
 func NewAvailabilityRepository(pool *pgxpool.Pool) *AvailabilityRepository {
 	return &AvailabilityRepository{
@@ -57,51 +258,70 @@ type AvailabilityRepository struct {
 	db *pgxpool.Pool
 }
 
-const baseAvailabilityQuery = `SELECT a.id, a.product_id, a.date, p.capacity, (
-		SELECT count(*)
-		FROM ventrata.bookings b
-		JOIN ventrata.tickets t ON b.id = t.booking_id
-		WHERE b.availability_id = a.id
-	) AS booked
+// availabilityChangedChannel is notified by bookings trigger with ID of availability whose vacancies might have changed
+const availabilityChangedChannel = "availability_changed"
+
+// baseAvailabilityQuery reads booked counter of availability, it is kept by tickets trigger
+const baseAvailabilityQuery = `SELECT a.id, a.product_id, a.date, p.capacity, a.booked
 FROM ventrata.availability a
 JOIN ventrata.products p ON p.id = a.product_id`
 
-func (a *AvailabilityRepository) GetLatestAvailability(ctx context.Context, productID uuid.UUID) (*Availability, error) {
+func (a *AvailabilityRepository) GetAvailabilityCoverage(ctx context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) (map[uuid.UUID]AvailabilityCoverage, error) {
 	rows, err := a.db.Query(
 		ctx,
-		fmt.Sprintf(
-			"%s WHERE a.product_id = $1 AND date = (SELECT max(date) FROM ventrata.availability WHERE product_id = $1 GROUP BY product_id)",
-			baseAvailabilityQuery,
-		),
-		productID,
+		`SELECT p.id,
+	max(a.date) AS latest_date,
+	coalesce(array_agg(a.date ORDER BY a.date) FILTER (WHERE $2 <= a.date AND a.date <= $3), '{}') AS dates
+FROM ventrata.products p
+LEFT JOIN ventrata.availability a ON a.product_id = p.id
+WHERE p.id = ANY($1)
+GROUP BY p.id`,
+		productIDs,
+		from,
+		to,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("could not query latest availability: %w", err)
+		return nil, fmt.Errorf("querying availability coverage failed: %w", err)
 	}
 	defer rows.Close()
-	availabilities, err := a.scanAvailability(rows)
-	if err != nil {
-		return nil, fmt.Errorf("could not scan latest availability: %w", err)
+
+	coverage := make(map[uuid.UUID]AvailabilityCoverage, len(productIDs))
+	for rows.Next() {
+		var productID uuid.UUID
+		var c AvailabilityCoverage
+		if err := rows.Scan(&productID, &c.LatestDate, &c.Dates); err != nil {
+			return nil, fmt.Errorf("scanning availability coverage failed: %w", err)
+		}
+		coverage[productID] = c
 	}
-	if len(availabilities) == 0 {
-		return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("processing availability coverage rows failed: %w", err)
 	}
-	return &availabilities[0], nil
+	return coverage, nil
 }
 
-func (a *AvailabilityRepository) InsertAvailabilities(ctx context.Context, availabilities []Availability) error {
-	_, err := a.db.CopyFrom(
+func (a *AvailabilityRepository) InsertAvailabilities(ctx context.Context, availabilities []Availability) (int64, error) {
+	ids := make([]uuid.UUID, 0, len(availabilities))
+	productIDs := make([]uuid.UUID, 0, len(availabilities))
+	dates := make([]time.Time, 0, len(availabilities))
+	for _, availability := range availabilities {
+		ids = append(ids, availability.ID)
+		productIDs = append(productIDs, availability.ProductID)
+		dates = append(dates, time.Time(availability.LocalDate))
+	}
+	tag, err := a.db.Exec(
 		ctx,
-		pgx.Identifier{"ventrata", "availability"},
-		[]string{"id", "product_id", "date"},
-		pgx.CopyFromSlice(len(availabilities), func(i int) ([]interface{}, error) {
-			return []any{availabilities[i].ID, availabilities[i].ProductID, time.Time(availabilities[i].LocalDate)}, nil
-		}),
+		`INSERT INTO ventrata.availability (id, product_id, date)
+SELECT * FROM unnest($1::uuid[], $2::uuid[], $3::timestamptz[])
+ON CONFLICT (product_id, date) DO NOTHING`,
+		ids,
+		productIDs,
+		dates,
 	)
 	if err != nil {
-		return fmt.Errorf("could not insert availability: %w", err)
+		return 0, fmt.Errorf("could not insert availability: %w", err)
 	}
-	return nil
+	return tag.RowsAffected(), nil
 }
 
 func (a *AvailabilityRepository) GetAvailabilityByID(ctx context.Context, id uuid.UUID) (Availability, error) {
@@ -118,12 +338,12 @@ func (a *AvailabilityRepository) GetAvailabilityByID(ctx context.Context, id uui
 	}
 
 	defer rows.Close()
-	availabilities, err := a.scanAvailability(rows)
+	availabilities, err := scanAvailabilities(rows)
 	if err != nil {
 		return Availability{}, err
 	}
 	if len(availabilities) == 0 {
-		return Availability{}, pkg.NewNotFoundError("availability was not found")
+		return Availability{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeAvailabilityNotFound, fmt.Sprintf("availability %s not found", id))
 	}
 	return availabilities[0], nil
 }
@@ -143,17 +363,21 @@ func (a *AvailabilityRepository) GetAvailability(ctx context.Context, productID
 	}
 
 	defer rows.Close()
-	return a.scanAvailability(rows)
+	return scanAvailabilities(rows)
 }
 
 func (a *AvailabilityRepository) GetAvailabilityTo(ctx context.Context, productID uuid.UUID, from time.Time, to time.Time) ([]Availability, error) {
+	return a.GetProductsAvailabilityTo(ctx, []uuid.UUID{productID}, from, to)
+}
+
+func (a *AvailabilityRepository) GetProductsAvailabilityTo(ctx context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) ([]Availability, error) {
 	rows, err := a.db.Query(
 		ctx,
 		fmt.Sprintf(
-			"%s WHERE a.product_id = $1 AND $2 <= a.date AND a.date <= $3",
+			"%s WHERE a.product_id = ANY($1) AND $2 <= a.date AND a.date <= $3 ORDER BY a.date",
 			baseAvailabilityQuery,
 		),
-		productID,
+		productIDs,
 		from,
 		to,
 	)
@@ -162,10 +386,156 @@ func (a *AvailabilityRepository) GetAvailabilityTo(ctx context.Context, productI
 	}
 
 	defer rows.Close()
-	return a.scanAvailability(rows)
+	return scanAvailabilities(rows)
+}
+
+func (a *AvailabilityRepository) GetProductsNotCovered(ctx context.Context, today time.Time, slackDays int) ([]uuid.UUID, error) {
+	rows, err := a.db.Query(
+		ctx,
+		`SELECT p.id
+FROM ventrata.products p
+WHERE (p.sales_cutoff IS NULL OR p.sales_cutoff >= $1::date)
+AND NOT EXISTS (
+	SELECT 1
+	FROM ventrata.availability a
+	WHERE a.product_id = p.id
+	AND a.date >= least($1::timestamptz + make_interval(days => greatest(p.horizon_days - $2, 0)), p.sales_cutoff)
+)`,
+		today,
+		slackDays,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying products not covered by availability failed: %w", err)
+	}
+	defer rows.Close()
+
+	productIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("scanning products not covered by availability failed: %w", err)
+	}
+	return productIDs, nil
+}
+
+func (a *AvailabilityRepository) ReconcileBookedCounters(ctx context.Context, fix bool) ([]BookedCounterMismatch, error) {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin reconciliation transaction failed: %w", err)
+	}
+	defer func() {
+		// read only reconciliation is always rolled back, failed commit has nothing to roll back
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.ErrorContext(ctx, "rolling back reconciliation transaction failed", pkg.Err(err))
+		}
+	}()
+
+	if fix {
+		// tickets can not change until counters are fixed, otherwise they would be counted from a stale snapshot,
+		// availabilities are locked before tickets are read as CreateBooking does, in order of their IDs
+		if _, err := tx.Exec(ctx, "SELECT 1 FROM ventrata.availability ORDER BY id FOR UPDATE"); err != nil {
+			return nil, fmt.Errorf("locking availabilities failed: %w", err)
+		}
+	}
+	rows, err := tx.Query(
+		ctx,
+		`SELECT a.id, a.product_id, a.date, a.booked, coalesce(c.tickets, 0) AS tickets
+FROM ventrata.availability a
+LEFT JOIN (
+	SELECT b.availability_id, count(*) AS tickets
+	FROM ventrata.bookings b
+	JOIN ventrata.tickets t ON b.id = t.booking_id
+	GROUP BY b.availability_id
+) c ON c.availability_id = a.id
+WHERE a.booked <> coalesce(c.tickets, 0)
+ORDER BY a.date, a.product_id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying booked counters failed: %w", err)
+	}
+	mismatches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (BookedCounterMismatch, error) {
+		var mismatch BookedCounterMismatch
+		var date time.Time
+		err := row.Scan(&mismatch.AvailabilityID, &mismatch.ProductID, &date, &mismatch.Booked, &mismatch.Tickets)
+		mismatch.LocalDate = JSONTime(date)
+		return mismatch, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning booked counters failed: %w", err)
+	}
+	if !fix || len(mismatches) == 0 {
+		return mismatches, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(mismatches))
+	tickets := make([]int, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		ids = append(ids, mismatch.AvailabilityID)
+		tickets = append(tickets, mismatch.Tickets)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE ventrata.availability a
+SET booked = m.tickets
+FROM unnest($1::uuid[], $2::integer[]) AS m(id, tickets)
+WHERE a.id = m.id`,
+		ids,
+		tickets,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fixing booked counters failed: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit reconciliation transaction failed: %w", err)
+	}
+	return mismatches, nil
 }
 
-func (a *AvailabilityRepository) scanAvailability(rows pgx.Rows) ([]Availability, error) {
+func (a *AvailabilityRepository) ListenAvailabilityChanges(ctx context.Context, notify func(availabilityID uuid.UUID)) error {
+	poolConn, err := a.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring availability listen connection failed: %w", err)
+	}
+	// connection in LISTEN state must not be returned to the pool
+	conn := poolConn.Hijack()
+	defer func() {
+		if err := conn.Close(context.Background()); err != nil {
+			slog.ErrorContext(ctx, "closing availability listen connection failed", pkg.Err(err))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, fmt.Sprintf("LISTEN %s", availabilityChangedChannel)); err != nil {
+		return fmt.Errorf("listening to availability changes failed: %w", err)
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for availability change failed: %w", err)
+		}
+		availabilityID, err := uuid.Parse(notification.Payload)
+		if err != nil {
+			slog.WarnContext(ctx, "received malformed availability change", slog.String("payload", notification.Payload))
+			continue
+		}
+		notify(availabilityID)
+	}
+}
+
+// ReconcileBookedCounters verifies booked counters of availabilities against their tickets, fix repairs them
+func (s *Server) ReconcileBookedCounters(ctx context.Context, fix bool) ([]BookedCounterMismatch, error) {
+	reconciler, ok := s.availabilityProcessor.(BookedCounterReconciler)
+	if !ok {
+		return nil, errBookedCountersNotKept
+	}
+	mismatches, err := reconciler.ReconcileBookedCounters(ctx, fix)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) > 0 {
+		s.logger.WarnContext(ctx, "booked counters differ from tickets", slog.Int("availabilities", len(mismatches)), slog.Bool("fixed", fix))
+	}
+	return mismatches, nil
+}
+
+func scanAvailabilities(rows pgx.Rows) ([]Availability, error) {
 	availabilities := make([]Availability, 0)
 	for rows.Next() {
 		var id uuid.UUID
@@ -181,14 +551,9 @@ func (a *AvailabilityRepository) scanAvailability(rows pgx.Rows) ([]Availability
 			ID:        id,
 			ProductID: productID,
 			LocalDate: JSONTime(date),
+			Status:    availabilityStatus(vacancies),
 			Vacancies: vacancies,
-		}
-		if vacancies > 0 {
-			a.Status = AvailabilityStatusAvailable
-			a.Available = true
-		} else {
-			a.Status = AvailabilityStatusSoldOut
-			a.Available = false
+			Available: vacancies > 0,
 		}
 
 		availabilities = append(availabilities, a)
@@ -199,3 +564,10 @@ func (a *AvailabilityRepository) scanAvailability(rows pgx.Rows) ([]Availability
 	}
 	return availabilities, nil
 }
+
+func availabilityStatus(vacancies int) string {
+	if vacancies > 0 {
+		return AvailabilityStatusAvailable
+	}
+	return AvailabilityStatusSoldOut
+}
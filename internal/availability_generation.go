@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+const JobBackfillAvailabilities = "backfill-availabilities"
+
+const (
+	// availabilityGenerationTimeout bounds every database call of the generation, so that one stuck product
+	// does not consume time of the others
+	availabilityGenerationTimeout    = 10 * time.Second
+	availabilityGenerationAttempts   = 3
+	availabilityGenerationRetryDelay = 100 * time.Millisecond
+	// availabilityCreationTimeout bounds scheduled generation of all products, so that it can not hang forever
+	availabilityCreationTimeout = 5 * time.Minute
+	// productPageSize is number of products read by one query
+	productPageSize = 500
+)
+
+// ErrAvailabilityGenerationRunning is returned when another replica is generating availabilities
+var ErrAvailabilityGenerationRunning = errors.New("availabilities are being generated by another replica")
+
+type AvailabilityGenerationParams struct {
+	// ProductIDs limits generation to these products, all products are generated when empty
+	ProductIDs []uuid.UUID `json:"productIds"`
+	// From and To are inclusive bounds of generated days, days beyond sales window of a product are never generated,
+	// zero To generates up to the end of the sales window
+	From   JSONTime `json:"localDateStart"`
+	To     JSONTime `json:"localDateEnd"`
+	DryRun bool     `json:"dryRun"`
+}
+
+type ProductAvailabilityGeneration struct {
+	ProductID uuid.UUID `json:"productId"`
+	// LatestDate is the last day product had availability for before generation
+	LatestDate *JSONTime `json:"latestDate"`
+	// Planned is number of missing days
+	Planned  int    `json:"planned"`
+	Inserted int64  `json:"inserted"`
+	Error    string `json:"error,omitempty"`
+}
+
+type AvailabilityGenerationResult struct {
+	Products []ProductAvailabilityGeneration `json:"products"`
+	Inserted int64                           `json:"inserted"`
+	Failed   int                             `json:"failed"`
+	DryRun   bool                            `json:"dryRun"`
+	// Availabilities are days that would be inserted, they are reported only on dry run
+	Availabilities []Availability `json:"availabilities,omitempty"`
+}
+
+// err joins errors of products that failed
+func (a AvailabilityGenerationResult) err() error {
+	errs := make([]error, 0, a.Failed)
+	for _, product := range a.Products {
+		if product.Error != "" {
+			errs = append(errs, fmt.Errorf("product %s: %s", product.ProductID, product.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateAvailabilities generates availabilities so that whole sales window of every product is bookable,
+// only one replica runs it at a time
+func (s *Server) CreateAvailabilities() {
+	ctx, cFunc := context.WithTimeout(context.Background(), availabilityCreationTimeout)
+	defer cFunc()
+	today := s.clock.Now().UTC().Truncate(24 * time.Hour)
+	result, err := s.GenerateAvailabilities(ctx, JobCreateAvailabilities, AvailabilityGenerationParams{
+		From: JSONTime(today),
+	})
+	if errors.Is(err, ErrAvailabilityGenerationRunning) {
+		s.logger.InfoContext(ctx, "availabilities are being created by another replica")
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create availabilities", pkg.Err(err))
+		return
+	}
+	if result.Failed > 0 {
+		s.logger.ErrorContext(ctx, "failed to create availabilities for some products", pkg.Err(result.err()), slog.Int64("inserted", result.Inserted))
+		return
+	}
+	s.logger.InfoContext(ctx, "availabilities created", slog.Int64("inserted", result.Inserted))
+}
+
+// GenerateAvailabilities inserts every missing day between params.From and params.To, run is recorded as job,
+// dry run only reports the missing days. Failure of a product does not stop generation of the others,
+// it is reported in the result.
+func (s *Server) GenerateAvailabilities(ctx context.Context, job string, params AvailabilityGenerationParams) (AvailabilityGenerationResult, error) {
+	// requested range is bounded for every caller, generation up to the end of sales window is bounded by the product
+	if !time.Time(params.To).IsZero() {
+		if invalidParams := validateDateRange("localDateStart", "localDateEnd", time.Time(params.From), time.Time(params.To)); len(invalidParams) > 0 {
+			return AvailabilityGenerationResult{}, pkg.NewDomainError(
+				pkg.ErrValidation,
+				pkg.ProblemCodeUnprocessable,
+				fmt.Sprintf("range of generated days is invalid, %s: %s", invalidParams[0].Name, invalidParams[0].Reason),
+				invalidParams...,
+			)
+		}
+	}
+	if params.DryRun {
+		return s.generateAvailabilities(ctx, params)
+	}
+
+	var result AvailabilityGenerationResult
+	completed := false
+	ran, err := s.runJob(ctx, job, availabilityJobLockKey, func(ctx context.Context) (int64, error) {
+		var err error
+		result, err = s.generateAvailabilities(ctx, params)
+		if err != nil {
+			return 0, err
+		}
+		completed = true
+		return result.Inserted, result.err()
+	})
+	if !ran && err == nil {
+		return AvailabilityGenerationResult{}, ErrAvailabilityGenerationRunning
+	}
+	availabilityGenerationRunsTotal.Inc()
+	if err != nil {
+		availabilityGenerationFailuresTotal.Inc()
+	}
+	if !completed {
+		return AvailabilityGenerationResult{}, err
+	}
+	if err != nil && result.Failed == 0 {
+		// generation succeeded, only recording of the run failed
+		s.logger.ErrorContext(ctx, "failed to record availability generation", pkg.Err(err))
+	}
+	return result, nil
+}
+
+func (s *Server) generateAvailabilities(ctx context.Context, params AvailabilityGenerationParams) (AvailabilityGenerationResult, error) {
+	products, err := s.selectProducts(ctx, params.ProductIDs)
+	if err != nil {
+		return AvailabilityGenerationResult{}, err
+	}
+	today := s.clock.Now().UTC().Truncate(24 * time.Hour)
+	from := time.Time(params.From)
+	var to time.Time
+	productIDs := make([]uuid.UUID, 0, len(products))
+	productTo := make(map[uuid.UUID]time.Time, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+		lastSaleDay := product.lastSaleDay(today)
+		if !time.Time(params.To).IsZero() && time.Time(params.To).Before(lastSaleDay) {
+			lastSaleDay = time.Time(params.To)
+		}
+		productTo[product.ID] = lastSaleDay
+		if lastSaleDay.After(to) {
+			to = lastSaleDay
+		}
+	}
+
+	var coverage map[uuid.UUID]AvailabilityCoverage
+	err = retryAvailabilityGeneration(ctx, func(ctx context.Context) error {
+		var err error
+		coverage, err = s.availabilityProcessor.GetAvailabilityCoverage(ctx, productIDs, from, to)
+		return err
+	})
+	if err != nil {
+		return AvailabilityGenerationResult{}, fmt.Errorf("failed to get availability coverage: %w", err)
+	}
+
+	result := AvailabilityGenerationResult{
+		Products: make([]ProductAvailabilityGeneration, 0, len(products)),
+		DryRun:   params.DryRun,
+	}
+	for _, product := range products {
+		productCoverage := coverage[product.ID]
+		availabilities := planProductAvailabilities(s.newID, product.ID, productCoverage, from, productTo[product.ID])
+		summary := ProductAvailabilityGeneration{
+			ProductID: product.ID,
+			Planned:   len(availabilities),
+		}
+		if productCoverage.LatestDate != nil {
+			latestDate := JSONTime(*productCoverage.LatestDate)
+			summary.LatestDate = &latestDate
+		}
+
+		if params.DryRun {
+			result.Availabilities = append(result.Availabilities, availabilities...)
+		} else if len(availabilities) > 0 {
+			err := retryAvailabilityGeneration(ctx, func(ctx context.Context) error {
+				inserted, err := s.availabilityProcessor.InsertAvailabilities(ctx, availabilities)
+				summary.Inserted = inserted
+				return err
+			})
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to insert availabilities", pkg.Err(err), slog.String("product_id", product.ID.String()))
+				summary.Error = err.Error()
+				result.Failed++
+			}
+			result.Inserted += summary.Inserted
+		}
+		result.Products = append(result.Products, summary)
+	}
+	return result, nil
+}
+
+// selectProducts returns products with productIDs, all products when productIDs is empty
+func (s *Server) selectProducts(ctx context.Context, productIDs []uuid.UUID) ([]Product, error) {
+	if len(productIDs) == 0 {
+		return s.listAllProducts(ctx)
+	}
+	products, err := s.productProcessor.GetProducts(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	// products are returned in requested order, so that grouped responses are stable
+	selected := make([]Product, 0, len(productIDs))
+	for _, productID := range productIDs {
+		index := slices.IndexFunc(products, func(product Product) bool { return product.ID == productID })
+		if index < 0 {
+			return nil, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeProductNotFound, fmt.Sprintf("product %s not found", productID))
+		}
+		selected = append(selected, products[index])
+	}
+	return selected, nil
+}
+
+// listAllProducts reads all products page by page, so that no query reads unbounded number of rows
+func (s *Server) listAllProducts(ctx context.Context) ([]Product, error) {
+	products := make([]Product, 0)
+	after := uuid.Nil
+	for {
+		page, err := s.productProcessor.ListProducts(ctx, after, productPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list products: %w", err)
+		}
+		products = append(products, page...)
+		if len(page) < productPageSize {
+			return products, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// planProductAvailabilities returns availabilities for days between from and to that are not covered yet
+func planProductAvailabilities(newID IDGenerator, productID uuid.UUID, coverage AvailabilityCoverage, from time.Time, to time.Time) []Availability {
+	existingDays := make(map[time.Time]struct{}, len(coverage.Dates))
+	for _, date := range coverage.Dates {
+		existingDays[date.UTC()] = struct{}{}
+	}
+	availabilities := make([]Availability, 0)
+	for day := from.UTC(); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if _, ok := existingDays[day]; ok {
+			continue
+		}
+		availabilities = append(availabilities, Availability{
+			ID:        newID(),
+			ProductID: productID,
+			LocalDate: JSONTime(day),
+		})
+	}
+	return availabilities
+}
+
+// retryAvailabilityGeneration calls fn until it succeeds, each attempt has its own timeout
+func retryAvailabilityGeneration(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := range availabilityGenerationAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(availabilityGenerationRetryDelay * time.Duration(attempt)):
+			}
+		}
+		attemptCtx, cFunc := context.WithTimeout(ctx, availabilityGenerationTimeout)
+		err = fn(attemptCtx)
+		cFunc()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func validateAvailabilityGenerationParams(params AvailabilityGenerationParams) []pkg.InvalidParam {
+	invalidParams := make([]pkg.InvalidParam, 0, 2)
+	if time.Time(params.From).IsZero() || time.Time(params.To).IsZero() {
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "localDateStart",
+			Reason: "localDateStart and localDateEnd are required",
+		})
+	} else {
+		invalidParams = append(invalidParams, validateDateRange("localDateStart", "localDateEnd", time.Time(params.From), time.Time(params.To))...)
+	}
+	return invalidParams
+}
+
+func (s *Server) generateAvailabilitiesHandler(_ http.ResponseWriter, r *http.Request) (any, error) {
+	var params AvailabilityGenerationParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, pkg.NewBadRequestError(pkg.InvalidParam{
+			Name:   "Body",
+			Reason: err.Error(),
+		})
+	}
+	if invalidParams := validateAvailabilityGenerationParams(params); len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+	result, err := s.GenerateAvailabilities(r.Context(), JobBackfillAvailabilities, params)
+	if errors.Is(err, ErrAvailabilityGenerationRunning) {
+		return nil, pkg.NewConflictError(ProblemCodeGenerationRunning, err.Error())
+	}
+	return result, err
+}
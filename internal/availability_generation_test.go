@@ -0,0 +1,455 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+func TestPlanProductAvailabilities_NewProduct(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 6)
+
+	availabilities := planProductAvailabilities(uuid.New, uuid.New(), AvailabilityCoverage{}, from, to)
+
+	if len(availabilities) != 7 {
+		t.Fatalf("expected 7 availabilities, got %d", len(availabilities))
+	}
+	for i, availability := range availabilities {
+		if expected := from.AddDate(0, 0, i); !time.Time(availability.LocalDate).Equal(expected) {
+			t.Fatalf("expected day %s, got %s", expected, time.Time(availability.LocalDate))
+		}
+	}
+}
+
+func TestPlanProductAvailabilities_FillsGaps(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 4)
+	latestDate := to
+	coverage := AvailabilityCoverage{
+		LatestDate: &latestDate,
+		Dates:      []time.Time{from, from.AddDate(0, 0, 2), to},
+	}
+
+	availabilities := planProductAvailabilities(uuid.New, uuid.New(), coverage, from, to)
+
+	if len(availabilities) != 2 {
+		t.Fatalf("expected 2 availabilities, got %d", len(availabilities))
+	}
+	for i, expected := range []time.Time{from.AddDate(0, 0, 1), from.AddDate(0, 0, 3)} {
+		if !time.Time(availabilities[i].LocalDate).Equal(expected) {
+			t.Fatalf("expected gap %s to be filled, got %s", expected, time.Time(availabilities[i].LocalDate))
+		}
+	}
+}
+
+func TestPlanProductAvailabilities_Covered(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	coverage := AvailabilityCoverage{
+		Dates: []time.Time{from, from.AddDate(0, 0, 1)},
+	}
+
+	availabilities := planProductAvailabilities(uuid.New, uuid.New(), coverage, from, from.AddDate(0, 0, 1))
+
+	if len(availabilities) != 0 {
+		t.Fatalf("expected no availabilities, got %d", len(availabilities))
+	}
+}
+
+func TestServer_GenerateAvailabilities_IsolatesProductFailures(t *testing.T) {
+	failing := uuid.New()
+	flaky := uuid.New()
+	healthy := uuid.New()
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+
+	availabilityProcessor := &stubAvailabilityProcessor{
+		failures: map[uuid.UUID]int{
+			failing: availabilityGenerationAttempts,
+			flaky:   1,
+		},
+	}
+	jobRunProcessor := &stubJobRunProcessor{}
+	processors := memoryProcessors(NewMemoryStore())
+	processors.Product = stubProductProcessor{
+		products: []Product{{ID: failing}, {ID: flaky}, {ID: healthy}},
+	}
+	processors.Availability = availabilityProcessor
+	processors.JobRun = jobRunProcessor
+	processors.Locker = stubLocker{}
+	s, err := NewServerWithProcessors(DefaultConfig(), processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.GenerateAvailabilities(context.Background(), JobCreateAvailabilities, AvailabilityGenerationParams{
+		From: JSONTime(from),
+		To:   JSONTime(to),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Failed != 1 {
+		t.Fatalf("expected 1 failed product, got %d", result.Failed)
+	}
+	if result.Inserted != 6 {
+		t.Fatalf("expected 6 inserted availabilities, got %d", result.Inserted)
+	}
+	for _, product := range result.Products {
+		if product.Planned != 3 {
+			t.Fatalf("expected 3 planned days for product %s, got %d", product.ProductID, product.Planned)
+		}
+		if (product.Error != "") != (product.ProductID == failing) {
+			t.Fatalf("unexpected error %q for product %s", product.Error, product.ProductID)
+		}
+	}
+	if jobRunProcessor.jobErr == nil {
+		t.Fatal("expected failure to be recorded in job run")
+	}
+	if jobRunProcessor.rowsInserted != 6 {
+		t.Fatalf("expected job run to record 6 inserted rows, got %d", jobRunProcessor.rowsInserted)
+	}
+}
+
+type stubProductProcessor struct {
+	ProductProcessor
+	products []Product
+}
+
+func (s stubProductProcessor) ListProducts(_ context.Context, after uuid.UUID, limit int) ([]Product, error) {
+	if after != uuid.Nil {
+		return []Product{}, nil
+	}
+	return s.products[:min(limit, len(s.products))], nil
+}
+
+func (s stubProductProcessor) GetProduct(_ context.Context, id uuid.UUID) (Product, error) {
+	for _, product := range s.products {
+		if product.ID == id {
+			return product, nil
+		}
+	}
+	return Product{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeProductNotFound, fmt.Sprintf("product %s not found", id))
+}
+
+// stubAvailabilityProcessor has no availabilities, inserting fails for products as many times as set in failures
+type stubAvailabilityProcessor struct {
+	AvailabilityProcessor
+	failures map[uuid.UUID]int
+}
+
+func (s *stubAvailabilityProcessor) GetAvailabilityCoverage(_ context.Context, _ []uuid.UUID, _ time.Time, _ time.Time) (map[uuid.UUID]AvailabilityCoverage, error) {
+	return map[uuid.UUID]AvailabilityCoverage{}, nil
+}
+
+func (s *stubAvailabilityProcessor) InsertAvailabilities(_ context.Context, availabilities []Availability) (int64, error) {
+	productID := availabilities[0].ProductID
+	if s.failures[productID] > 0 {
+		s.failures[productID]--
+		return 0, errors.New("connection reset")
+	}
+	return int64(len(availabilities)), nil
+}
+
+type stubJobRunProcessor struct {
+	JobRunProcessor
+	rowsInserted int64
+	jobErr       error
+	// finishCtxErr is error of context the run was finished with
+	finishCtxErr error
+}
+
+func (s *stubJobRunProcessor) StartJobRun(_ context.Context, _ string, _ time.Time) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (s *stubJobRunProcessor) FinishJobRun(ctx context.Context, _ uuid.UUID, _ time.Time, rowsInserted int64, jobErr error) error {
+	s.finishCtxErr = ctx.Err()
+	s.rowsInserted = rowsInserted
+	s.jobErr = jobErr
+	return nil
+}
+
+type stubLocker struct{}
+
+func (stubLocker) TryWithLock(_ context.Context, _ int64, fn func() error) (bool, error) {
+	return true, fn()
+}
+
+func TestServer_runJob_SingleLeader(t *testing.T) {
+	store := NewMemoryStore()
+	leader := newMemoryServer(t, store)
+	follower := newMemoryServer(t, store)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := leader.runJob(ctx, JobCreateAvailabilities, availabilityJobLockKey, func(context.Context) (int64, error) {
+			close(started)
+			<-release
+			return 0, nil
+		})
+		done <- err
+	}()
+	<-started
+
+	_, err := follower.GenerateAvailabilities(ctx, JobCreateAvailabilities, AvailabilityGenerationParams{From: JSONTime(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))})
+	if !errors.Is(err, ErrAvailabilityGenerationRunning) {
+		t.Fatalf("expected follower to skip generation while leader runs it, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := store.ListJobRuns(ctx, JobCreateAvailabilities, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].FinishedAt == nil {
+		t.Fatalf("expected only the leader run to be recorded, got %+v", runs)
+	}
+}
+
+func TestAdvisoryLocker_SingleLeader(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	// every replica has its own pool
+	lockers := make([]*AdvisoryLocker, 0, 2)
+	for range 2 {
+		pool, err := pgxpool.New(ctx, pgConn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(pool.Close)
+		lockers = append(lockers, NewAdvisoryLocker(pool))
+	}
+
+	ran, err := lockers[0].TryWithLock(ctx, availabilityJobLockKey, func() error {
+		followerRan, err := lockers[1].TryWithLock(ctx, availabilityJobLockKey, func() error { return nil })
+		if err != nil {
+			return err
+		}
+		if followerRan {
+			return errors.New("expected follower not to run while leader holds the lock")
+		}
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("expected leader to run, got %v %v", ran, err)
+	}
+	if ran, err := lockers[1].TryWithLock(ctx, availabilityJobLockKey, func() error { return nil }); err != nil || !ran {
+		t.Fatalf("expected lock to be released after leader run, got %v %v", ran, err)
+	}
+}
+
+func TestServer_runJob_RecordsCancelledRun(t *testing.T) {
+	jobRunProcessor := &stubJobRunProcessor{}
+	processors := memoryProcessors(NewMemoryStore())
+	processors.JobRun = jobRunProcessor
+	processors.Locker = stubLocker{}
+	s, err := NewServerWithProcessors(DefaultConfig(), processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err = s.runJob(ctx, JobCreateAvailabilities, availabilityJobLockKey, func(ctx context.Context) (int64, error) {
+		cancel()
+		return 0, ctx.Err()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected job error to be returned, got %v", err)
+	}
+	if jobRunProcessor.finishCtxErr != nil || !errors.Is(jobRunProcessor.jobErr, context.Canceled) {
+		t.Fatalf("expected cancelled run to be recorded with live context, got %v %v", jobRunProcessor.finishCtxErr, jobRunProcessor.jobErr)
+	}
+}
+
+func TestServer_CreateAvailabilities_FastForward(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		start time.Time
+		// firstDay is UTC day of start
+		firstDay time.Time
+	}{
+		{name: "year end", start: time.Date(2024, 12, 29, 23, 59, 0, 0, time.UTC), firstDay: time.Date(2024, 12, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "daylight saving time starts", start: time.Date(2024, 3, 29, 0, 30, 0, 0, prague), firstDay: time.Date(2024, 3, 28, 0, 0, 0, 0, time.UTC)},
+		{name: "daylight saving time ends", start: time.Date(2024, 11, 1, 21, 0, 0, 0, newYork), firstDay: time.Date(2024, 11, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			product := Product{ID: uuid.New(), Name: "product", Capacity: 1, HorizonDays: 2}
+			store.AddProduct(product)
+			clock := newTestClock(tt.start)
+			s := newMemoryServer(t, store, WithClock(clock))
+
+			// daily job runs at the same local time on 5 consecutive days
+			for day := range 5 {
+				clock.Set(tt.start.AddDate(0, 0, day))
+				s.CreateAvailabilities()
+
+				lastDay := tt.firstDay.AddDate(0, 0, day+product.HorizonDays)
+				availabilities, err := store.GetAvailabilityTo(context.Background(), product.ID, tt.firstDay, lastDay.AddDate(0, 0, 1))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(availabilities) != day+product.HorizonDays+1 {
+					t.Fatalf("day %d: expected %d availabilities, got %d", day, day+product.HorizonDays+1, len(availabilities))
+				}
+				for i, availability := range availabilities {
+					if expected := tt.firstDay.AddDate(0, 0, i); !time.Time(availability.LocalDate).Equal(expected) {
+						t.Fatalf("day %d: expected availability on %s, got %s", day, expected, time.Time(availability.LocalDate))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestServer_listAllProducts(t *testing.T) {
+	store := NewMemoryStore()
+	for i := range productPageSize + 1 {
+		store.AddProduct(Product{ID: uuid.New(), Name: fmt.Sprintf("product %d", i), Capacity: 1, HorizonDays: 1})
+	}
+	products, err := newMemoryServer(t, store).listAllProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != productPageSize+1 {
+		t.Fatalf("expected all products to be listed over more pages, got %d", len(products))
+	}
+}
+
+func TestServer_generateAvailabilitiesHandler(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	clock := newTestClock(time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC))
+	s := newMemoryServer(t, store, WithClock(clock))
+	generate := func(body string) (AvailabilityGenerationResult, error) {
+		t.Helper()
+		result, err := s.generateAvailabilitiesHandler(nil, httptest.NewRequest(http.MethodPost, "/admin/v1/availability/generate", strings.NewReader(body)))
+		if err != nil {
+			return AvailabilityGenerationResult{}, err
+		}
+		return result.(AvailabilityGenerationResult), nil
+	}
+
+	for _, body := range []string{
+		`{"localDateStart": "2024-05-20"}`,
+		`{"localDateStart": "2024-05-20", "localDateEnd": "2024-05-19"}`,
+		`{"localDateStart": "2024-05-20", "localDateEnd": "2025-05-22"}`,
+	} {
+		var badRequest *pkg.BadRequestError
+		if _, err := generate(body); !errors.As(err, &badRequest) {
+			t.Fatalf("expected %s to be rejected, got %v", body, err)
+		}
+	}
+
+	body := `{"productIds": ["` + product.ID.String() + `"], "localDateStart": "2024-05-20", "localDateEnd": "2024-05-26", "dryRun": true}`
+	result, err := generate(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || len(result.Availabilities) != 7 || result.Inserted != 0 {
+		t.Fatalf("expected dry run to plan 7 days without inserting them, got %+v", result)
+	}
+	result, err = generate(`{"localDateStart": "2024-05-20", "localDateEnd": "2025-05-20"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 366 || result.Failed != 0 {
+		t.Fatalf("expected longest range to be generated, got %+v", result)
+	}
+}
+
+func TestServer_GenerateAvailabilities_RangeLimit(t *testing.T) {
+	s := newMemoryServer(t, NewMemoryStore())
+	from := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	_, err := s.GenerateAvailabilities(context.Background(), JobBackfillAvailabilities, AvailabilityGenerationParams{
+		From: JSONTime(from),
+		To:   JSONTime(from.AddDate(2, 0, 0)),
+	})
+	if !errors.Is(err, pkg.ErrValidation) {
+		t.Fatalf("expected backfill of two years to be rejected, got %v", err)
+	}
+}
+
+func TestServer_GenerateAvailabilities_Repository(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	cfg := DefaultConfig()
+	cfg.Database.DSN = pgConn
+	s, err := NewServer(cfg, WithoutScheduler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	productID := uuid.New()
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 10)", productID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.availability(id, product_id, date) VALUES ($1, $2, $3)", uuid.New(), productID, from.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	params := AvailabilityGenerationParams{ProductIDs: []uuid.UUID{productID}, From: JSONTime(from), To: JSONTime(from.AddDate(0, 0, 6))}
+
+	result, err := s.GenerateAvailabilities(ctx, JobBackfillAvailabilities, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 6 || result.Failed != 0 {
+		t.Fatalf("expected 6 missing days to be inserted, got %+v", result)
+	}
+	result, err = s.GenerateAvailabilities(ctx, JobBackfillAvailabilities, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 0 {
+		t.Fatalf("expected repeated backfill to insert nothing, got %+v", result)
+	}
+	var days int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM ventrata.availability WHERE product_id = $1", productID).Scan(&days); err != nil {
+		t.Fatal(err)
+	}
+	if days != 7 {
+		t.Fatalf("expected every day to exist once, got %d", days)
+	}
+}
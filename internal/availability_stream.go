@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+const (
+	// availabilitySubscriptionBuffer is number of events subscriber can lag behind before it is disconnected
+	availabilitySubscriptionBuffer  = 16
+	availabilityStreamKeepAlive     = 15 * time.Second
+	availabilityListenRetryInterval = time.Second
+)
+
+type availabilitySubscription struct {
+	// productID, from and to are optional filters, zero value matches everything
+	productID uuid.UUID
+	from      time.Time
+	to        time.Time
+	events    chan Availability
+}
+
+func (a *availabilitySubscription) matches(availability Availability) bool {
+	if a.productID != (uuid.UUID{}) && a.productID != availability.ProductID {
+		return false
+	}
+	date := time.Time(availability.LocalDate)
+	if !a.from.IsZero() && date.Before(a.from) {
+		return false
+	}
+	if !a.to.IsZero() && date.After(a.to) {
+		return false
+	}
+	return true
+}
+
+func newAvailabilityBroker() *availabilityBroker {
+	return &availabilityBroker{
+		subscriptions: map[*availabilitySubscription]struct{}{},
+	}
+}
+
+// availabilityBroker fans out availability changes to stream subscribers of this replica
+type availabilityBroker struct {
+	mu            sync.Mutex
+	subscriptions map[*availabilitySubscription]struct{}
+	closed        bool
+}
+
+func (b *availabilityBroker) subscribe(productID uuid.UUID, from time.Time, to time.Time) *availabilitySubscription {
+	subscription := &availabilitySubscription{
+		productID: productID,
+		from:      from,
+		to:        to,
+		events:    make(chan Availability, availabilitySubscriptionBuffer),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(subscription.events)
+		return subscription
+	}
+	b.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+func (b *availabilityBroker) unsubscribe(subscription *availabilitySubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscriptions[subscription]; ok {
+		delete(b.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+func (b *availabilityBroker) publish(availability Availability) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscription := range b.subscriptions {
+		if !subscription.matches(availability) {
+			continue
+		}
+		select {
+		case subscription.events <- availability:
+		default:
+			// slow subscriber would miss changes, disconnect it so that client reconnects and reads fresh state
+			delete(b.subscriptions, subscription)
+			close(subscription.events)
+		}
+	}
+}
+
+// close disconnects all subscribers, subscriptions made after close are closed immediately
+func (b *availabilityBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for subscription := range b.subscriptions {
+		delete(b.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+// listenAvailabilityChanges publishes availability changes made by any replica until ctx is done
+func (s *Server) listenAvailabilityChanges(ctx context.Context) {
+	for {
+		err := s.availabilityProcessor.ListenAvailabilityChanges(ctx, func(availabilityID uuid.UUID) {
+			availability, err := s.availabilityProcessor.GetAvailabilityByID(ctx, availabilityID)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to get changed availability", pkg.Err(err))
+				return
+			}
+			s.availabilityBroker.publish(availability)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.ErrorContext(ctx, "listening to availability changes failed", pkg.Err(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(availabilityListenRetryInterval):
+		}
+	}
+}
+
+func (s *Server) streamAvailability(w http.ResponseWriter, r *http.Request) {
+	capability := getCapabilityHeader(r)
+	invalidParams := validateCapability(capability)
+	productID, from, to, validationErrors := parseAvailabilityStreamQuery(r.URL.Query())
+	invalidParams = append(invalidParams, validationErrors...)
+	if len(invalidParams) > 0 {
+		pkg.WriteError(w, r, pkg.NewBadRequestError(invalidParams...))
+		return
+	}
+
+	// stream outlives server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		pkg.WriteError(w, r, fmt.Errorf("could not extend write deadline of availability stream: %w", err))
+		return
+	}
+
+	subscription := s.availabilityBroker.subscribe(productID, from, to)
+	defer s.availabilityBroker.unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to flush availability stream", pkg.Err(err))
+		return
+	}
+
+	keepAlive := time.NewTicker(availabilityStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case availability, ok := <-subscription.events:
+			if !ok {
+				return
+			}
+			if err := s.writeAvailabilityEvent(r.Context(), w, availability, capability); err != nil {
+				s.logger.ErrorContext(r.Context(), "failed to write availability event", pkg.Err(err))
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			s.logger.ErrorContext(r.Context(), "failed to flush availability stream", pkg.Err(err))
+			return
+		}
+	}
+}
+
+func (s *Server) writeAvailabilityEvent(ctx context.Context, w http.ResponseWriter, availability Availability, capability string) error {
+	var event any = availability
+	if capability == CapabilityPricing {
+		pricedAvailabilities, err := s.pricingProcessor.GetPricedAvailabilities(ctx, []Availability{availability}, s.config.DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		event = pricedAvailabilities[0]
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: availability\nid: %s\ndata: %s\n\n", availability.ID, data)
+	return err
+}
+
+func parseAvailabilityStreamQuery(query url.Values) (uuid.UUID, time.Time, time.Time, []pkg.InvalidParam) {
+	invalidParams := make([]pkg.InvalidParam, 0, 3)
+	var productID uuid.UUID
+	if productIDStr := query.Get("productId"); productIDStr != "" {
+		parsed, err := uuid.Parse(productIDStr)
+		if err != nil {
+			invalidParams = append(invalidParams, pkg.InvalidParam{
+				Name:   "productId",
+				Reason: "query parameter productId is malformed",
+			})
+		}
+		productID = parsed
+	}
+	from, validationErrors := parseDateQuery(query, "localDateStart")
+	invalidParams = append(invalidParams, validationErrors...)
+	to, validationErrors := parseDateQuery(query, "localDateEnd")
+	invalidParams = append(invalidParams, validationErrors...)
+	return productID, from, to, invalidParams
+}
+
+func parseDateQuery(query url.Values, name string) (time.Time, []pkg.InvalidParam) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(timeFormat, value)
+	if err != nil {
+		return time.Time{}, []pkg.InvalidParam{
+			{
+				Name:   name,
+				Reason: fmt.Sprintf("query parameter %s must be in format %s", name, timeFormat),
+			},
+		}
+	}
+	return date, nil
+}
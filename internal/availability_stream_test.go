@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAvailabilityBroker_publish(t *testing.T) {
+	productID := uuid.New()
+	date := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	broker := newAvailabilityBroker()
+	matching := broker.subscribe(productID, date.AddDate(0, 0, -1), date.AddDate(0, 0, 1))
+	otherProduct := broker.subscribe(uuid.New(), time.Time{}, time.Time{})
+	outOfRange := broker.subscribe(productID, date.AddDate(0, 0, 1), time.Time{})
+
+	availability := Availability{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(date)}
+	broker.publish(availability)
+
+	select {
+	case received := <-matching.events:
+		if received.ID != availability.ID {
+			t.Fatalf("expected availability %s, got %s", availability.ID, received.ID)
+		}
+	default:
+		t.Fatal("matching subscription did not receive availability")
+	}
+	if len(otherProduct.events) != 0 {
+		t.Fatal("subscription of other product received availability")
+	}
+	if len(outOfRange.events) != 0 {
+		t.Fatal("subscription out of date range received availability")
+	}
+}
+
+func TestAvailabilityBroker_slowSubscriberDisconnected(t *testing.T) {
+	broker := newAvailabilityBroker()
+	subscription := broker.subscribe(uuid.UUID{}, time.Time{}, time.Time{})
+
+	for range availabilitySubscriptionBuffer + 1 {
+		broker.publish(Availability{ID: uuid.New()})
+	}
+
+	for range availabilitySubscriptionBuffer {
+		<-subscription.events
+	}
+	if _, ok := <-subscription.events; ok {
+		t.Fatal("expected slow subscription to be closed")
+	}
+	// unsubscribing disconnected subscription must not close channel twice
+	broker.unsubscribe(subscription)
+}
+
+func TestServer_streamAvailability(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	store.SetPricing(product.ID, Pricing{Price: 1000, Currency: "EUR"})
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	availabilities := []Availability{
+		{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today.AddDate(0, 0, 1))},
+		{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today.AddDate(0, 0, 5))},
+	}
+	if _, err := store.InsertAvailabilities(context.Background(), availabilities); err != nil {
+		t.Fatal(err)
+	}
+	s := newMemoryServer(t, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.listenAvailabilityChanges(ctx)
+	handler, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	query := "?productId=" + product.ID.String() + "&localDateEnd=" + today.AddDate(0, 0, 2).Format(timeFormat)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/availability/stream"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Capability", CapabilityPricing)
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected event stream, got %d %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	// headers are sent after subscription, listener of the store might not be registered yet
+	for {
+		store.mu.RLock()
+		listening := len(store.listeners) > 0
+		store.mu.RUnlock()
+		if listening {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// booking out of the streamed range is filtered out, so the first event is of the second booking
+	for _, availability := range []Availability{availabilities[1], availabilities[0]} {
+		if _, err := store.CreateBooking(context.Background(), availability, 2, BookingDetails{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := bufio.NewScanner(response.Body)
+	for events.Scan() {
+		data, ok := strings.CutPrefix(events.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event PricedAvailability
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.ID != availabilities[0].ID || event.Vacancies != 8 || event.Price != 1000 {
+			t.Fatalf("expected priced availability %s with 8 vacancies, got %+v", availabilities[0].ID, event)
+		}
+		return
+	}
+	t.Fatalf("expected availability event, stream ended with %v", events.Err())
+}
@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestAvailabilityRepository_InsertAvailabilities_SkipsExistingDays(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := uuid.New()
+	_, err = pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 10)", productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	availabilityRepository := NewAvailabilityRepository(pool)
+	inserted, err := availabilityRepository.InsertAvailabilities(ctx, []Availability{
+		{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(date)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 1 {
+		t.Fatalf("expected 1 inserted availability, got %d", inserted)
+	}
+
+	// another replica generating the same days must not create duplicates
+	inserted, err = availabilityRepository.InsertAvailabilities(ctx, []Availability{
+		{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(date)},
+		{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(date.AddDate(0, 0, 1))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 1 {
+		t.Fatalf("expected only 1 new day to be inserted, got %d", inserted)
+	}
+	availabilities, err := availabilityRepository.GetAvailability(ctx, productID, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(availabilities) != 1 {
+		t.Fatalf("expected 1 availability for the day, got %d", len(availabilities))
+	}
+}
+
+func TestAvailabilityRepository_GetAvailabilityCoverage(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coveredProductID := uuid.New()
+	newProductID := uuid.New()
+	for _, productID := range []uuid.UUID{coveredProductID, newProductID} {
+		_, err = pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 10)", productID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	latestDate := from.AddDate(0, 1, 0)
+	availabilityRepository := NewAvailabilityRepository(pool)
+	_, err = availabilityRepository.InsertAvailabilities(ctx, []Availability{
+		{ID: uuid.New(), ProductID: coveredProductID, LocalDate: JSONTime(from)},
+		{ID: uuid.New(), ProductID: coveredProductID, LocalDate: JSONTime(from.AddDate(0, 0, 2))},
+		{ID: uuid.New(), ProductID: coveredProductID, LocalDate: JSONTime(latestDate)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coverage, err := availabilityRepository.GetAvailabilityCoverage(ctx, []uuid.UUID{coveredProductID, newProductID}, from, from.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := coverage[coveredProductID]
+	if covered.LatestDate == nil || !covered.LatestDate.Equal(latestDate) {
+		t.Fatalf("expected latest date %s, got %v", latestDate, covered.LatestDate)
+	}
+	if len(covered.Dates) != 2 {
+		t.Fatalf("expected 2 days in range, got %d", len(covered.Dates))
+	}
+	newProduct, ok := coverage[newProductID]
+	if !ok {
+		t.Fatal("expected coverage of product without availabilities")
+	}
+	if newProduct.LatestDate != nil || len(newProduct.Dates) != 0 {
+		t.Fatalf("expected empty coverage of new product, got %+v", newProduct)
+	}
+}
+
+// countingAvailabilityQuery is how availability was read before booked counters, it counts tickets of every row
+const countingAvailabilityQuery = `SELECT a.id, a.product_id, a.date, p.capacity, (
+		SELECT count(*)
+		FROM ventrata.bookings b
+		JOIN ventrata.tickets t ON b.id = t.booking_id
+		WHERE b.availability_id = a.id
+	) AS booked
+FROM ventrata.availability a
+JOIN ventrata.products p ON p.id = a.product_id
+WHERE a.product_id = $1 AND $2 <= a.date AND a.date <= $3`
+
+// calendarFullYearTarget is the longest calendar of a full year of busy product may take
+const calendarFullYearTarget = 50 * time.Millisecond
+
+// seedFullYear adds product with full year of availability on data similar to the seed, every day is booked by
+// 5 bookings of 20 units, it returns pool and the product with its range
+func seedFullYear(tb testing.TB) (*pgxpool.Pool, uuid.UUID, time.Time, time.Time) {
+	tb.Helper()
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+
+	productID := uuid.New()
+	_, err = pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'Museum entry', 300)", productID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, "INSERT INTO ventrata.pricing(product_id, currency, price) VALUES ($1, 'EUR', 1000)", productID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	to := from.AddDate(1, 0, 0)
+	availabilities := make([]Availability, 0, 366)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		availabilities = append(availabilities, Availability{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(day)})
+	}
+	if _, err := NewAvailabilityRepository(pool).InsertAvailabilities(ctx, availabilities); err != nil {
+		tb.Fatal(err)
+	}
+	bookingRepository := NewBookingRepository(pool)
+	for _, availability := range availabilities {
+		availability.Vacancies = 300
+		for range 5 {
+			if _, err := bookingRepository.CreateBooking(ctx, availability, 20, BookingDetails{}); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return pool, productID, from, to
+}
+
+// TestAvailabilityRepository_GetAvailabilityCalendar_FullYear asserts calendarFullYearTarget, the fastest of a few
+// runs is compared, so that one slow run of a busy machine does not fail it
+func TestAvailabilityRepository_GetAvailabilityCalendar_FullYear(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing of calendar is not measured in short mode")
+	}
+	pool, productID, from, to := seedFullYear(t)
+	availabilityRepository := NewAvailabilityRepository(pool)
+	ctx := context.Background()
+
+	fastest := time.Duration(math.MaxInt64)
+	for range 5 {
+		start := time.Now()
+		days, err := availabilityRepository.GetAvailabilityCalendar(ctx, productID, from, to, "EUR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fastest = min(fastest, time.Since(start))
+		if expected := int(to.Sub(from).Hours()/24) + 1; len(days) != expected {
+			t.Fatalf("expected calendar of %d days, got %d", expected, len(days))
+		}
+	}
+	if fastest > calendarFullYearTarget {
+		t.Fatalf("expected calendar of full year to take at most %s, took %s", calendarFullYearTarget, fastest)
+	}
+}
+
+// BenchmarkAvailabilityRepository_FullYear reads full year of availability, booked counters are compared with
+// counting tickets of every row, calendar must stay under calendarFullYearTarget
+func BenchmarkAvailabilityRepository_FullYear(b *testing.B) {
+	pool, productID, from, to := seedFullYear(b)
+	ctx := context.Background()
+	availabilityRepository := NewAvailabilityRepository(pool)
+
+	b.Run("counting tickets", func(b *testing.B) {
+		for range b.N {
+			rows, err := pool.Query(ctx, countingAvailabilityQuery, productID, from, to)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := scanAvailabilities(rows); err != nil {
+				b.Fatal(err)
+			}
+			rows.Close()
+		}
+	})
+	b.Run("booked counters", func(b *testing.B) {
+		for range b.N {
+			if _, err := availabilityRepository.GetAvailabilityTo(ctx, productID, from, to); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("calendar", func(b *testing.B) {
+		for range b.N {
+			if _, err := availabilityRepository.GetAvailabilityCalendar(ctx, productID, from, to, "EUR"); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if perOp := b.Elapsed() / time.Duration(b.N); perOp > calendarFullYearTarget {
+			b.Errorf("calendar took %s, target is %s", perOp, calendarFullYearTarget)
+		}
+	})
+}
+
+func TestAvailabilityRepository_ReconcileBookedCounters(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := uuid.New()
+	_, err = pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 10)", productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	availability := Availability{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(time.Now().UTC().Truncate(24 * time.Hour))}
+	availabilityRepository := NewAvailabilityRepository(pool)
+	if _, err := availabilityRepository.InsertAvailabilities(ctx, []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBookingRepository(pool).CreateBooking(ctx, availability, 3, BookingDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := availabilityRepository.ReconcileBookedCounters(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("expected booking to keep counter consistent, got %+v", mismatches)
+	}
+
+	if _, err := pool.Exec(ctx, "UPDATE ventrata.availability SET booked = 0 WHERE id = $1", availability.ID); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err = availabilityRepository.ReconcileBookedCounters(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Booked != 0 || mismatches[0].Tickets != 3 {
+		t.Fatalf("expected 1 mismatch of 0 booked and 3 tickets, got %+v", mismatches)
+	}
+	current, err := availabilityRepository.GetAvailabilityByID(ctx, availability.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Vacancies != 7 {
+		t.Fatalf("expected fixed counter to leave 7 vacancies, got %d", current.Vacancies)
+	}
+}
+
+func TestAvailabilityRepository_BookedCounterDecrement(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := uuid.New()
+	_, err = pool.Exec(ctx, "INSERT INTO ventrata.products(id, name, capacity) VALUES ($1, 'product', 10)", productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	availability := Availability{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(time.Now().UTC().Truncate(24 * time.Hour))}
+	availabilityRepository := NewAvailabilityRepository(pool)
+	if _, err := availabilityRepository.InsertAvailabilities(ctx, []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	booking, err := NewBookingRepository(pool).CreateBooking(ctx, availability, 3, BookingDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVacancies := func(vacancies int) {
+		t.Helper()
+		current, err := availabilityRepository.GetAvailabilityByID(ctx, availability.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Vacancies != vacancies {
+			t.Fatalf("expected %d vacancies, got %d", vacancies, current.Vacancies)
+		}
+	}
+	expectVacancies(7)
+
+	if _, err := pool.Exec(ctx, "DELETE FROM ventrata.tickets WHERE id = $1", booking.Units[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	expectVacancies(8)
+
+	// booking is deleted with its tickets in one transaction
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if _, err := tx.Exec(ctx, "DELETE FROM ventrata.tickets WHERE booking_id = $1", booking.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM ventrata.bookings WHERE id = $1", booking.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectVacancies(10)
+}
+
+func TestServer_ReconcileBookedCounters_MemoryStore(t *testing.T) {
+	s := newMemoryServer(t, NewMemoryStore())
+	if _, err := s.ReconcileBookedCounters(context.Background(), false); !errors.Is(err, errBookedCountersNotKept) {
+		t.Fatalf("expected memory store to have no booked counters to reconcile, got %v", err)
+	}
+}
+
+func TestDecodeAvailabilityRequest(t *testing.T) {
+	productID := uuid.New().String()
+	manyProductIDs := make([]string, 0, 51)
+	for range 51 {
+		manyProductIDs = append(manyProductIDs, `"`+uuid.NewString()+`"`)
+	}
+	tests := []struct {
+		name          string
+		body          string
+		days          int
+		products      int
+		invalidParams []string
+	}{
+		{name: "day", body: `{"productId": "` + productID + `", "localDate": "2024-06-01"}`, days: 1},
+		{name: "range", body: `{"productId": "` + productID + `", "localDateStart": "2024-06-01", "localDateEnd": "2024-06-30"}`, days: 30},
+		{name: "whole leap year", body: `{"productId": "` + productID + `", "localDateStart": "2024-01-01", "localDateEnd": "2025-01-01"}`, days: 367},
+		{
+			name:          "end before start without product",
+			body:          `{"localDateStart": "2024-06-30", "localDateEnd": "2024-06-01"}`,
+			invalidParams: []string{"productId", "localDateEnd"},
+		},
+		{name: "too long", body: `{"productId": "` + productID + `", "localDateStart": "2024-01-01", "localDateEnd": "2044-01-01"}`, invalidParams: []string{"localDateEnd"}},
+		{name: "missing end", body: `{"productId": "` + productID + `", "localDateStart": "2024-01-01"}`, invalidParams: []string{"localDateEnd"}},
+		{name: "both variants", body: `{"productId": "` + productID + `", "localDate": "2024-01-01", "localDateEnd": "2024-01-02"}`, invalidParams: []string{"localDate"}},
+		{name: "no date", body: `{"productId": "` + productID + `"}`, invalidParams: []string{"localDate"}},
+		{name: "more products", body: `{"productIds": ["` + productID + `"], "localDate": "2024-06-01"}`, days: 1, products: 1},
+		{name: "duplicate products", body: `{"productIds": ["` + productID + `", "` + productID + `"], "localDate": "2024-06-01"}`, days: 1, products: 1},
+		{name: "many products for a day", body: `{"productIds": [` + strings.Join(manyProductIDs, ", ") + `], "localDate": "2024-06-01"}`, days: 1, products: 51},
+		{
+			name:          "many products for a year",
+			body:          `{"productIds": [` + strings.Join(manyProductIDs, ", ") + `], "localDateStart": "2024-01-01", "localDateEnd": "2025-01-01"}`,
+			invalidParams: []string{"productIds"},
+		},
+		{name: "all products", body: `{"allProducts": true, "localDateStart": "2024-06-01", "localDateEnd": "2024-06-07"}`, days: 7},
+		{name: "product and all products", body: `{"productId": "` + productID + `", "allProducts": true, "localDate": "2024-06-01"}`, invalidParams: []string{"productIds"}},
+		{name: "empty product in products", body: `{"productIds": ["` + uuid.Nil.String() + `"], "localDate": "2024-06-01"}`, invalidParams: []string{"productIds"}},
+		{name: "unknown field", body: `{"productId": "` + productID + `", "localDate": "2024-06-01", "units": 1}`, invalidParams: []string{"Body"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, from, to, invalidParams := decodeAvailabilityRequest(strings.NewReader(tt.body))
+			names := make([]string, 0, len(invalidParams))
+			for _, invalidParam := range invalidParams {
+				names = append(names, invalidParam.Name)
+			}
+			if !slices.Equal(names, tt.invalidParams) && len(names)+len(tt.invalidParams) > 0 {
+				t.Fatalf("expected invalid params %v, got %+v", tt.invalidParams, invalidParams)
+			}
+			if tt.days > 0 && int(to.Sub(from)/(24*time.Hour))+1 != tt.days {
+				t.Fatalf("expected %d days, got range %s - %s", tt.days, from, to)
+			}
+			if tt.products > 0 && len(request.ProductIds) != tt.products {
+				t.Fatalf("expected %d distinct products, got %v", tt.products, request.ProductIds)
+			}
+		})
+	}
+}
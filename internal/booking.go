@@ -22,6 +22,7 @@ type Booking struct {
 	ProductID      uuid.UUID `json:"productId"`
 	AvailabilityID uuid.UUID `json:"availabilityId"`
 	Units          []Unit    `json:"units"`
+	BookingDetails
 }
 
 const (
@@ -29,10 +30,14 @@ const (
 	BookingStatusConfirmed = "CONFIRMED"
 )
 
+// confirmedTicketContent is content of every ticket of confirmed booking
+const confirmedTicketContent = "my awesome ticket"
+
 type BookingRequest struct {
 	ProductID      uuid.UUID `json:"productId"`
 	AvailabilityID uuid.UUID `json:"availabilityId"`
 	Units          int       `json:"units"`
+	BookingDetails
 }
 
 type Ticket struct {
@@ -41,32 +46,40 @@ type Ticket struct {
 	Content   string
 }
 
+// baseBookingQuery selects rows of bookings with their tickets for scanBookings
+const baseBookingQuery = `SELECT b.id, b.availability_id, b.confirmed, t.id AS ticket_id, t.content AS ticket_content, a.product_id,
+	COALESCE(c.full_name, ''), COALESCE(c.email_address, ''), COALESCE(c.phone_number, ''),
+	COALESCE(c.locale, ''), COALESCE(c.country, ''), COALESCE(n.notes, ''), COALESCE(n.reseller_reference, '')
+FROM ventrata.bookings b
+JOIN ventrata.tickets t ON b.id = t.booking_id
+JOIN ventrata.availability a ON a.id = b.availability_id
+LEFT JOIN ventrata.booking_contacts c ON c.booking_id = b.id
+LEFT JOIN ventrata.booking_notes n ON n.booking_id = b.id`
+
 type BookingProcessor interface {
-	CreateBooking(ctx context.Context, availability Availability, units int) (Booking, error)
+	CreateBooking(ctx context.Context, availability Availability, units int, details BookingDetails) (Booking, error)
 	GetBooking(ctx context.Context, bookingID uuid.UUID) (Booking, error)
 	ConfirmBooking(ctx context.Context, bookingID uuid.UUID) (Booking, error)
+	// UpdateBookingDetails replaces details of booking by result of update, update gets current details and runs
+	// while the booking is locked, so concurrent updates do not overwrite each other
+	UpdateBookingDetails(ctx context.Context, bookingID uuid.UUID, update func(details BookingDetails) (BookingDetails, error)) (Booking, error)
 }
 
 var _ BookingProcessor = &BookingRepository{}
 
 func NewBookingRepository(pool *pgxpool.Pool) *BookingRepository {
 	return &BookingRepository{
-		db: pool,
+		db:    pool,
+		newID: uuid.New,
 	}
 }
 
 type BookingRepository struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	newID IDGenerator
 }
 
-func (b *BookingRepository) CreateBooking(ctx context.Context, availability Availability, units int) (Booking, error) {
-	if availability.Vacancies < units {
-		return Booking{}, pkg.NewBadRequestError(pkg.InvalidParam{
-			Name:   "units",
-			Reason: "units is greater than availability vacancies",
-		})
-	}
-
+func (b *BookingRepository) CreateBooking(ctx context.Context, availability Availability, units int, details BookingDetails) (Booking, error) {
 	tx, err := b.db.Begin(ctx)
 	if err != nil {
 		return Booking{}, fmt.Errorf("begin booking creation transaction failed: %w", err)
@@ -83,11 +96,31 @@ func (b *BookingRepository) CreateBooking(ctx context.Context, availability Avai
 		}
 	}()
 
-	bookingID := uuid.New()
+	// vacancies are counted again under lock of the availability, so that concurrent bookings can not overbook it
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM ventrata.availability WHERE id = $1 FOR UPDATE", availability.ID); err != nil {
+		return Booking{}, fmt.Errorf("locking availability failed: %w", err)
+	}
+	rows, err := tx.Query(ctx, baseAvailabilityQuery+" WHERE a.id = $1", availability.ID)
+	if err != nil {
+		return Booking{}, fmt.Errorf("querying availability by id failed: %w", err)
+	}
+	current, err := scanAvailabilities(rows)
+	rows.Close()
+	if err != nil {
+		return Booking{}, err
+	}
+	if len(current) == 0 {
+		return Booking{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeAvailabilityNotFound, fmt.Sprintf("availability %s not found", availability.ID))
+	}
+	if err := checkVacancies(current[0], units); err != nil {
+		return Booking{}, err
+	}
+
+	bookingID := b.newID()
 	tickets := make([]Ticket, 0, units)
 	for range units {
 		tickets = append(tickets, Ticket{
-			ID:        uuid.New(),
+			ID:        b.newID(),
 			BookingID: bookingID,
 			// ticket content will be available after booking confirmation
 			Content: "",
@@ -114,6 +147,9 @@ func (b *BookingRepository) CreateBooking(ctx context.Context, availability Avai
 	if err != nil {
 		return Booking{}, fmt.Errorf("insert booking tickets failed: %w", err)
 	}
+	if err := saveBookingDetails(ctx, tx, bookingID, details); err != nil {
+		return Booking{}, err
+	}
 	if err := tx.Commit(ctx); err != nil {
 		return Booking{}, fmt.Errorf("commit booking creation transaction failed: %w", err)
 	}
@@ -125,11 +161,7 @@ func (b *BookingRepository) CreateBooking(ctx context.Context, availability Avai
 func (b *BookingRepository) GetBooking(ctx context.Context, bookingID uuid.UUID) (Booking, error) {
 	rows, err := b.db.Query(
 		ctx,
-		`SELECT b.id, b.availability_id, b.confirmed, t.id AS ticket_id, t.content AS ticket_content, a.product_id
-FROM ventrata.bookings b
-JOIN ventrata.tickets t ON b.id = t.booking_id
-JOIN ventrata.availability a ON a.id = b.availability_id
-WHERE b.id = $1`,
+		baseBookingQuery+" WHERE b.id = $1",
 		bookingID,
 	)
 	if err != nil {
@@ -142,7 +174,7 @@ WHERE b.id = $1`,
 		return Booking{}, err
 	}
 	if len(bookings) == 0 {
-		return Booking{}, pkg.NewNotFoundError(fmt.Sprintf("booking %s not found", bookingID))
+		return Booking{}, newBookingNotFoundError(bookingID)
 	}
 	return bookings[0], nil
 }
@@ -153,10 +185,7 @@ func (b *BookingRepository) ConfirmBooking(ctx context.Context, bookingID uuid.U
 		return Booking{}, err
 	}
 	if booking.Status == BookingStatusConfirmed {
-		return Booking{}, pkg.NewBadRequestError(pkg.InvalidParam{
-			Name:   "bookingId",
-			Reason: "booking already confirmed",
-		})
+		return Booking{}, newBookingAlreadyConfirmedError(bookingID)
 	}
 	tx, err := b.db.Begin(ctx)
 	if err != nil {
@@ -173,11 +202,15 @@ func (b *BookingRepository) ConfirmBooking(ctx context.Context, bookingID uuid.U
 		}
 	}()
 
-	_, err = tx.Exec(ctx, "UPDATE ventrata.bookings SET confirmed = TRUE WHERE id = $1", bookingID)
+	tag, err := tx.Exec(ctx, "UPDATE ventrata.bookings SET confirmed = TRUE WHERE id = $1 AND NOT confirmed", bookingID)
 	if err != nil {
 		return Booking{}, fmt.Errorf("updating booking status failed: %w", err)
 	}
-	_, err = tx.Exec(ctx, "UPDATE ventrata.tickets SET content = 'my awesome ticket' WHERE booking_id = $1", bookingID)
+	// booking might have been confirmed by concurrent request since it was read
+	if tag.RowsAffected() == 0 {
+		return Booking{}, newBookingAlreadyConfirmedError(bookingID)
+	}
+	_, err = tx.Exec(ctx, "UPDATE ventrata.tickets SET content = $2 WHERE booking_id = $1", bookingID, confirmedTicketContent)
 	if err != nil {
 		return Booking{}, fmt.Errorf("updating booking tickets failed: %w", err)
 	}
@@ -194,6 +227,29 @@ func (b *BookingRepository) ConfirmBooking(ctx context.Context, bookingID uuid.U
 	return booking, err
 }
 
+// checkVacancies checks that availability has vacancies for units
+func checkVacancies(availability Availability, units int) error {
+	if availability.Vacancies == 0 {
+		return pkg.NewDomainError(pkg.ErrConflict, ProblemCodeAvailabilitySoldOut, fmt.Sprintf("availability %s is sold out", availability.ID))
+	}
+	if availability.Vacancies < units {
+		return pkg.NewDomainError(
+			pkg.ErrConflict,
+			ProblemCodeInsufficientVacancies,
+			fmt.Sprintf("availability %s has only %d vacancies", availability.ID, availability.Vacancies),
+		)
+	}
+	return nil
+}
+
+func newBookingNotFoundError(bookingID uuid.UUID) error {
+	return pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeBookingNotFound, fmt.Sprintf("booking %s not found", bookingID))
+}
+
+func newBookingAlreadyConfirmedError(bookingID uuid.UUID) error {
+	return pkg.NewDomainError(pkg.ErrConflict, ProblemCodeBookingAlreadyConfirmed, fmt.Sprintf("booking %s is already confirmed", bookingID))
+}
+
 func (b *BookingRepository) scanBookings(rows pgx.Rows) ([]Booking, error) {
 	bookingsMap := make(map[uuid.UUID]*Booking)
 	for rows.Next() {
@@ -203,7 +259,23 @@ func (b *BookingRepository) scanBookings(rows pgx.Rows) ([]Booking, error) {
 		var ticketID uuid.UUID
 		var ticketContent string
 		var productID uuid.UUID
-		if err := rows.Scan(&id, &availabilityID, &confirmed, &ticketID, &ticketContent, &productID); err != nil {
+		var details BookingDetails
+		contact := &details.Contact
+		if err := rows.Scan(
+			&id,
+			&availabilityID,
+			&confirmed,
+			&ticketID,
+			&ticketContent,
+			&productID,
+			&contact.FullName,
+			&contact.EmailAddress,
+			&contact.PhoneNumber,
+			&contact.Locale,
+			&contact.Country,
+			&details.Notes,
+			&details.ResellerReference,
+		); err != nil {
 			return nil, fmt.Errorf("scanning bookings failed: %w", err)
 		}
 
@@ -226,6 +298,7 @@ func (b *BookingRepository) scanBookings(rows pgx.Rows) ([]Booking, error) {
 				ProductID:      productID,
 				AvailabilityID: availabilityID,
 				Status:         status,
+				BookingDetails: details,
 				Units: []Unit{
 					{
 						ID:     ticketID,
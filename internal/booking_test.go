@@ -21,9 +21,9 @@ func TestServer_createBooking_Concurrency(t *testing.T) {
 	}
 
 	t.Cleanup(cleanup)
-	s, err := NewServer(Config{
-		DatabaseDSN: pgConn,
-	})
+	cfg := DefaultConfig()
+	cfg.Database.DSN = pgConn
+	s, err := NewServer(cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+// AvailabilityCalendar is a compact view of product availability for booking widgets
+type AvailabilityCalendar struct {
+	ProductID uuid.UUID     `json:"productId"`
+	Days      []CalendarDay `json:"days"`
+}
+
+type CalendarDay struct {
+	LocalDate JSONTime `json:"localDate"`
+	Status    string   `json:"status"`
+	Vacancies int      `json:"vacancies"`
+	// LowestPrice is the cheapest price of the day, it is set only with pricing capability
+	LowestPrice *int   `json:"lowestPrice,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+}
+
+// GetAvailabilityCalendar reads vacancies of all days in one query, prices are joined only when currency is not empty
+func (a *AvailabilityRepository) GetAvailabilityCalendar(ctx context.Context, productID uuid.UUID, from time.Time, to time.Time, currency string) ([]CalendarDay, error) {
+	rows, err := a.db.Query(
+		ctx,
+		`SELECT a.date, p.capacity - a.booked AS vacancies, lowest.price
+FROM ventrata.availability a
+JOIN ventrata.products p ON p.id = a.product_id
+LEFT JOIN (
+	SELECT product_id, min(price) AS price
+	FROM ventrata.pricing
+	WHERE product_id = $1 AND currency = $4
+	GROUP BY product_id
+) lowest ON lowest.product_id = a.product_id
+WHERE a.product_id = $1 AND $2 <= a.date AND a.date <= $3
+ORDER BY a.date`,
+		productID,
+		from,
+		to,
+		currency,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying availability calendar failed: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]CalendarDay, 0)
+	for rows.Next() {
+		var date time.Time
+		var vacancies int
+		var price *int
+		if err := rows.Scan(&date, &vacancies, &price); err != nil {
+			return nil, fmt.Errorf("scanning availability calendar failed: %w", err)
+		}
+		day, err := newCalendarDay(productID, date, vacancies, price, currency)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("processing availability calendar rows failed: %w", err)
+	}
+	return days, nil
+}
+
+// newCalendarDay creates day of calendar, price is required when currency is requested
+func newCalendarDay(productID uuid.UUID, date time.Time, vacancies int, price *int, currency string) (CalendarDay, error) {
+	day := CalendarDay{
+		LocalDate: JSONTime(date),
+		Status:    availabilityStatus(vacancies),
+		Vacancies: vacancies,
+	}
+	if currency == "" {
+		return day, nil
+	}
+	if price == nil {
+		return CalendarDay{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodePricingNotFound, fmt.Sprintf("could not find pricing for product %s", productID))
+	}
+	day.LowestPrice = price
+	day.Currency = currency
+	return day, nil
+}
+
+func (s *Server) getAvailabilityCalendar(w http.ResponseWriter, r *http.Request) (any, error) {
+	invalidParams := make([]pkg.InvalidParam, 0, 10)
+
+	capability := getCapabilityHeader(r)
+	validationErrors := validateCapability(capability)
+	invalidParams = append(invalidParams, validationErrors...)
+
+	id, validationErrors := validateID(r.PathValue("id"))
+	invalidParams = append(invalidParams, validationErrors...)
+
+	from, to, validationErrors := parseDateRangeQuery(r.URL.Query())
+	invalidParams = append(invalidParams, validationErrors...)
+
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+	if to.IsZero() {
+		// month of from is shown by default
+		to = from.AddDate(0, 1, -from.Day())
+	}
+
+	product, err := s.productProcessor.GetProduct(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	// days are aggregated from the same availabilities, they are validated before the aggregate query
+	availabilities, err := s.availabilityProcessor.GetAvailabilityTo(r.Context(), product.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	w.Header().Set("Vary", "Capability")
+	if err := s.checkAvailabilityETag(w, r, product.ID, capability, availabilities); err != nil {
+		return nil, err
+	}
+	currency := ""
+	if capability == CapabilityPricing {
+		currency = s.config.DefaultCurrency
+	}
+	days, err := s.availabilityProcessor.GetAvailabilityCalendar(r.Context(), product.ID, from, to, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return AvailabilityCalendar{
+		ProductID: product.ID,
+		Days:      days,
+	}, nil
+}
+
+// parseDateRangeQuery parses required from and optional to query parameters, to is zero when it is missing
+func parseDateRangeQuery(query url.Values) (time.Time, time.Time, []pkg.InvalidParam) {
+	invalidParams := make([]pkg.InvalidParam, 0, 2)
+	from, validationErrors := parseDateQuery(query, "from")
+	invalidParams = append(invalidParams, validationErrors...)
+	to, validationErrors := parseDateQuery(query, "to")
+	invalidParams = append(invalidParams, validationErrors...)
+	if query.Get("from") == "" {
+		invalidParams = append(invalidParams, pkg.InvalidParam{
+			Name:   "from",
+			Reason: "query parameter from is required",
+		})
+	}
+	if !to.IsZero() {
+		invalidParams = append(invalidParams, validateDateRange("from", "to", from, to)...)
+	}
+	return from, to, invalidParams
+}
@@ -1,27 +1,310 @@
 package internal
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/prathoss/hw/pkg"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
 )
 
+// maskedSecret replaces secrets, it is the same mask url.URL.Redacted uses
+const maskedSecret = "xxxxx"
+
 type Config struct {
-	DatabaseDSN   string
-	ServerAddress string
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	// AdminToken protects admin endpoints, when empty admin endpoints are disabled
+	AdminToken   string             `yaml:"adminToken"`
+	Availability AvailabilityConfig `yaml:"availability"`
+	// DefaultCurrency is currency of prices returned with pricing capability
+	DefaultCurrency string            `yaml:"defaultCurrency"`
+	Privacy         PrivacyConfig     `yaml:"privacy"`
+	Tracing         pkg.TracingConfig `yaml:"tracing"`
+	Logging         pkg.LoggingConfig `yaml:"logging"`
 }
 
-func NewConfigFromEnv() (Config, error) {
-	serverAddress := os.Getenv("HW_SERVER_ADDRESS")
-	if serverAddress == "" {
-		serverAddress = "0.0.0.0:8080"
-	}
-	databaseDSN, err := pkg.ReadRequiredEnv("HW_DATABASE")
+type ServerConfig struct {
+	Address           string   `yaml:"address"`
+	ReadTimeout       Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      Duration `yaml:"writeTimeout"`
+	IdleTimeout       Duration `yaml:"idleTimeout"`
+	// ShutdownGrace is how long in-flight requests are waited for on shutdown
+	ShutdownGrace Duration `yaml:"shutdownGrace"`
+	// ValidateResponses validates responses against open api spec, responses are buffered so it is meant for tests
+	ValidateResponses bool `yaml:"validateResponses"`
+}
+
+type DatabaseConfig struct {
+	DSN      string `yaml:"dsn"`
+	MaxConns int    `yaml:"maxConns"`
+	MinConns int    `yaml:"minConns"`
+}
+
+type AvailabilityConfig struct {
+	// Schedule is cron expression of availability generation
+	Schedule string `yaml:"schedule"`
+}
+
+type PrivacyConfig struct {
+	// SubjectKey keys hashes of email addresses in privacy audit trail, data-subject requests are rejected without it
+	SubjectKey string `yaml:"subjectKey"`
+}
+
+// Duration is time.Duration read and written in time.ParseDuration format
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
 	if err != nil {
-		return Config{}, err
+		return err
 	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func DefaultConfig() Config {
 	return Config{
-		DatabaseDSN:   databaseDSN,
-		ServerAddress: serverAddress,
-	}, nil
+		Server: ServerConfig{
+			Address:           "0.0.0.0:8080",
+			ReadTimeout:       Duration(100 * time.Millisecond),
+			ReadHeaderTimeout: Duration(50 * time.Millisecond),
+			WriteTimeout:      Duration(100 * time.Millisecond),
+			IdleTimeout:       Duration(10 * time.Second),
+			ShutdownGrace:     Duration(5 * time.Second),
+		},
+		Database: DatabaseConfig{
+			MaxConns: 10,
+		},
+		Availability: AvailabilityConfig{
+			Schedule: "@daily",
+		},
+		DefaultCurrency: "EUR",
+		Tracing: pkg.TracingConfig{
+			Exporter:    pkg.TracingExporterNone,
+			ServiceName: "hw",
+		},
+		Logging: pkg.LoggingConfig{
+			Format: pkg.LogFormatText,
+			Level:  slog.LevelInfo,
+		},
+	}
+}
+
+// LoadConfig reads configuration, every source overrides the previous one: defaults, file at path (skipped when path
+// is empty), HW_* environment variables and overrides. All invalid values are reported together.
+func LoadConfig(path string, overrides ...func(cfg *Config) error) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	errs := cfg.readEnv()
+	for _, override := range overrides {
+		if err := override(&cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file failed: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s failed: %w", path, err)
+	}
+	return nil
+}
+
+type envVar struct {
+	name   string
+	target any
+}
+
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{name: "HW_SERVER_ADDRESS", target: &c.Server.Address},
+		{name: "HW_SERVER_READ_TIMEOUT", target: &c.Server.ReadTimeout},
+		{name: "HW_SERVER_READ_HEADER_TIMEOUT", target: &c.Server.ReadHeaderTimeout},
+		{name: "HW_SERVER_WRITE_TIMEOUT", target: &c.Server.WriteTimeout},
+		{name: "HW_SERVER_IDLE_TIMEOUT", target: &c.Server.IdleTimeout},
+		{name: "HW_SERVER_SHUTDOWN_GRACE", target: &c.Server.ShutdownGrace},
+		{name: "HW_SERVER_VALIDATE_RESPONSES", target: &c.Server.ValidateResponses},
+		{name: "HW_DATABASE", target: &c.Database.DSN},
+		{name: "HW_DATABASE_MAX_CONNS", target: &c.Database.MaxConns},
+		{name: "HW_DATABASE_MIN_CONNS", target: &c.Database.MinConns},
+		{name: "HW_ADMIN_TOKEN", target: &c.AdminToken},
+		{name: "HW_AVAILABILITY_SCHEDULE", target: &c.Availability.Schedule},
+		{name: "HW_DEFAULT_CURRENCY", target: &c.DefaultCurrency},
+		{name: "HW_PRIVACY_SUBJECT_KEY", target: &c.Privacy.SubjectKey},
+		{name: "HW_TRACING_EXPORTER", target: &c.Tracing.Exporter},
+		{name: "HW_TRACING_ENDPOINT", target: &c.Tracing.Endpoint},
+		{name: "HW_LOG_FORMAT", target: &c.Logging.Format},
+		{name: "HW_LOG_LEVEL", target: &c.Logging.Level},
+		{name: "HW_LOG_SOURCE", target: &c.Logging.AddSource},
+	}
+}
+
+func (c *Config) readEnv() []error {
+	errs := make([]error, 0)
+	for _, env := range c.envVars() {
+		value, ok := os.LookupEnv(env.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setConfigValue(env.target, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s is invalid: %w", env.name, err))
+		}
+	}
+	return errs
+}
+
+func setConfigValue(target any, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	case *Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*t = Duration(parsed)
+	case *slog.Level:
+		return t.UnmarshalText([]byte(value))
+	default:
+		return fmt.Errorf("unsupported config value type %T", target)
+	}
+	return nil
+}
+
+// minPrivacySubjectKeyLength is the shortest key, email addresses could be brute forced with shorter ones
+const minPrivacySubjectKeyLength = 32
+
+var currencyRegexp = regexp.MustCompile("^[A-Z]{3}$")
+
+// Validate returns all invalid values of config
+func (c Config) Validate() []error {
+	errs := make([]error, 0)
+	if _, _, err := net.SplitHostPort(c.Server.Address); err != nil {
+		errs = append(errs, fmt.Errorf("server.address is invalid: %w", err))
+	}
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{name: "server.readTimeout", value: c.Server.ReadTimeout},
+		{name: "server.readHeaderTimeout", value: c.Server.ReadHeaderTimeout},
+		{name: "server.writeTimeout", value: c.Server.WriteTimeout},
+		{name: "server.idleTimeout", value: c.Server.IdleTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", timeout.name))
+		}
+	}
+	// zero grace would cut in-flight requests off immediately
+	if c.Server.ShutdownGrace <= 0 {
+		errs = append(errs, errors.New("server.shutdownGrace must be positive"))
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required"))
+	}
+	if c.Database.MaxConns < 1 {
+		errs = append(errs, errors.New("database.maxConns must be positive"))
+	}
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		errs = append(errs, errors.New("database.minConns must be between 0 and database.maxConns"))
+	}
+	if _, err := cron.ParseStandard(c.Availability.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("availability.schedule is invalid: %w", err))
+	}
+	if !currencyRegexp.MatchString(c.DefaultCurrency) {
+		errs = append(errs, errors.New("defaultCurrency must be ISO 4217 code"))
+	}
+	if c.Privacy.SubjectKey != "" && len(c.Privacy.SubjectKey) < minPrivacySubjectKeyLength {
+		errs = append(errs, fmt.Errorf("privacy.subjectKey must have at least %d characters", minPrivacySubjectKeyLength))
+	}
+	switch c.Tracing.Exporter {
+	case pkg.TracingExporterNone, pkg.TracingExporterStdout, pkg.TracingExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of %s, %s, %s", pkg.TracingExporterNone, pkg.TracingExporterStdout, pkg.TracingExporterOTLP))
+	}
+	if c.Logging.Format != pkg.LogFormatText && c.Logging.Format != pkg.LogFormatJSON {
+		errs = append(errs, fmt.Errorf("logging.format must be one of %s, %s", pkg.LogFormatText, pkg.LogFormatJSON))
+	}
+	return errs
+}
+
+var dsnPasswordRegexp = regexp.MustCompile(`password=\S+`)
+
+// Masked returns copy of config with secrets replaced, so that it can be printed
+func (c Config) Masked() Config {
+	if c.AdminToken != "" {
+		c.AdminToken = maskedSecret
+	}
+	if c.Privacy.SubjectKey != "" {
+		c.Privacy.SubjectKey = maskedSecret
+	}
+	if dsn, err := url.Parse(c.Database.DSN); err == nil && dsn.Scheme != "" {
+		c.Database.DSN = dsn.Redacted()
+	} else {
+		c.Database.DSN = dsnPasswordRegexp.ReplaceAllString(c.Database.DSN, "password="+maskedSecret)
+	}
+	c.Tracing.Endpoint = maskEndpoint(c.Tracing.Endpoint)
+	return c
+}
+
+// maskEndpoint hides credentials of endpoint URL, they can be passed in user info or in query
+func maskEndpoint(endpoint string) string {
+	if endpoint == "" {
+		return ""
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return maskedSecret
+	}
+	if u.User != nil {
+		u.User = url.UserPassword(u.User.Username(), maskedSecret)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = maskedSecret
+	}
+	return u.String()
 }
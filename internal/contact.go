@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/prathoss/hw/pkg"
+)
+
+// BookingDetails are optional customer information of booking, they are given on creation and changed by PATCH
+type BookingDetails struct {
+	Contact Contact `json:"contact"`
+	// Notes are free text notes of the guest or reseller
+	Notes             string `json:"notes"`
+	ResellerReference string `json:"resellerReference"`
+}
+
+// Contact is the guest who can be contacted about the booking, e.g. when the tour is cancelled
+type Contact struct {
+	FullName     string `json:"fullName"`
+	EmailAddress string `json:"emailAddress"`
+	// PhoneNumber is in E.164 format
+	PhoneNumber string `json:"phoneNumber"`
+	// Locale is BCP 47 language tag, e.g. en-GB
+	Locale string `json:"locale"`
+	// Country is ISO 3166-1 alpha-2 code
+	Country string `json:"country"`
+}
+
+var _ slog.LogValuer = BookingDetails{}
+var _ slog.LogValuer = Contact{}
+
+// LogValue logs personal data under PII keys, so that the logger redacts them
+func (b BookingDetails) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("contact", b.Contact),
+		slog.String(pkg.PIIKeyNotes, b.Notes),
+		slog.String("resellerReference", b.ResellerReference),
+	)
+}
+
+// LogValue logs every field under its PII key, so that the logger redacts them
+func (c Contact) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String(pkg.PIIKeyFullName, c.FullName),
+		slog.String(pkg.PIIKeyEmailAddress, c.EmailAddress),
+		slog.String(pkg.PIIKeyPhoneNumber, c.PhoneNumber),
+		slog.String(pkg.PIIKeyLocale, c.Locale),
+		slog.String(pkg.PIIKeyCountry, c.Country),
+	)
+}
+
+const (
+	maxContactFieldLength = 255
+	maxNotesLength        = 2000
+)
+
+var (
+	phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+	localePattern      = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+	countryPattern     = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// validateBookingDetails validates set fields of details, all fields are optional
+func validateBookingDetails(details BookingDetails) []pkg.InvalidParam {
+	invalidParams := make([]pkg.InvalidParam, 0)
+	invalid := func(name string, reason string) {
+		invalidParams = append(invalidParams, pkg.InvalidParam{Name: name, Reason: reason})
+	}
+
+	contact := details.Contact
+	if utf8.RuneCountInString(contact.FullName) > maxContactFieldLength {
+		invalid("contact.fullName", fmt.Sprintf("Must have at most %d characters", maxContactFieldLength))
+	}
+	if contact.EmailAddress != "" && !isEmailAddress(contact.EmailAddress) {
+		invalid("contact.emailAddress", "Must be valid email address")
+	}
+	if contact.PhoneNumber != "" && !phoneNumberPattern.MatchString(contact.PhoneNumber) {
+		invalid("contact.phoneNumber", "Must be in E.164 format, e.g. +420123456789")
+	}
+	if contact.Locale != "" && !localePattern.MatchString(contact.Locale) {
+		invalid("contact.locale", "Must be BCP 47 language tag, e.g. en-GB")
+	}
+	if contact.Country != "" && !countryPattern.MatchString(contact.Country) {
+		invalid("contact.country", "Must be ISO 3166-1 alpha-2 code, e.g. CZ")
+	}
+	if utf8.RuneCountInString(details.Notes) > maxNotesLength {
+		invalid("notes", fmt.Sprintf("Must have at most %d characters", maxNotesLength))
+	}
+	if utf8.RuneCountInString(details.ResellerReference) > maxContactFieldLength {
+		invalid("resellerReference", fmt.Sprintf("Must have at most %d characters", maxContactFieldLength))
+	}
+	return invalidParams
+}
+
+// isEmailAddress reports whether value is email address, display names are not accepted
+func isEmailAddress(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value && len(value) <= maxContactFieldLength
+}
+
+// saveBookingDetails stores details of booking in tx, they replace previous details, rows are kept only for set values
+func saveBookingDetails(ctx context.Context, tx pgx.Tx, bookingID uuid.UUID, details BookingDetails) error {
+	contact := details.Contact
+	if contact == (Contact{}) {
+		if _, err := tx.Exec(ctx, "DELETE FROM ventrata.booking_contacts WHERE booking_id = $1", bookingID); err != nil {
+			return fmt.Errorf("removing booking contact failed: %w", err)
+		}
+	} else {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO ventrata.booking_contacts (booking_id, full_name, email_address, phone_number, locale, country)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (booking_id) DO UPDATE SET
+	full_name = excluded.full_name,
+	email_address = excluded.email_address,
+	phone_number = excluded.phone_number,
+	locale = excluded.locale,
+	country = excluded.country`,
+			bookingID,
+			contact.FullName,
+			contact.EmailAddress,
+			contact.PhoneNumber,
+			contact.Locale,
+			contact.Country,
+		)
+		if err != nil {
+			return fmt.Errorf("storing booking contact failed: %w", err)
+		}
+	}
+	if details.Notes == "" && details.ResellerReference == "" {
+		if _, err := tx.Exec(ctx, "DELETE FROM ventrata.booking_notes WHERE booking_id = $1", bookingID); err != nil {
+			return fmt.Errorf("removing booking notes failed: %w", err)
+		}
+		return nil
+	}
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO ventrata.booking_notes (booking_id, notes, reseller_reference)
+VALUES ($1, $2, $3)
+ON CONFLICT (booking_id) DO UPDATE SET notes = excluded.notes, reseller_reference = excluded.reseller_reference`,
+		bookingID,
+		details.Notes,
+		details.ResellerReference,
+	)
+	if err != nil {
+		return fmt.Errorf("storing booking notes failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BookingRepository) UpdateBookingDetails(ctx context.Context, bookingID uuid.UUID, update func(details BookingDetails) (BookingDetails, error)) (Booking, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return Booking{}, fmt.Errorf("begin booking update transaction failed: %w", err)
+	}
+
+	commitedTx := false
+	defer func() {
+		if commitedTx {
+			return
+		}
+		if err := tx.Rollback(ctx); err != nil {
+			slog.ErrorContext(ctx, "rolling back booking update transaction failed", pkg.Err(err))
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, "SELECT 1 FROM ventrata.bookings WHERE id = $1 FOR UPDATE", bookingID)
+	if err != nil {
+		return Booking{}, fmt.Errorf("locking booking failed: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return Booking{}, newBookingNotFoundError(bookingID)
+	}
+	// details are read under the lock, so that update merges into the latest ones
+	rows, err := tx.Query(ctx, baseBookingQuery+" WHERE b.id = $1", bookingID)
+	if err != nil {
+		return Booking{}, fmt.Errorf("querying booking failed: %w", err)
+	}
+	bookings, err := b.scanBookings(rows)
+	rows.Close()
+	if err != nil {
+		return Booking{}, err
+	}
+	if len(bookings) == 0 {
+		return Booking{}, newBookingNotFoundError(bookingID)
+	}
+	details, err := update(bookings[0].BookingDetails)
+	if err != nil {
+		return Booking{}, err
+	}
+	if err := saveBookingDetails(ctx, tx, bookingID, details); err != nil {
+		return Booking{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Booking{}, fmt.Errorf("commit booking update transaction failed: %w", err)
+	}
+	commitedTx = true
+
+	return b.GetBooking(ctx, bookingID)
+}
+
+// updateBooking changes details of booking, fields missing in the body keep their values and empty strings clear them
+func (s *Server) updateBooking(_ http.ResponseWriter, r *http.Request) (any, error) {
+	invalidParams := make([]pkg.InvalidParam, 0, 10)
+
+	capability := getCapabilityHeader(r)
+	validationErrors := validateCapability(capability)
+	invalidParams = append(invalidParams, validationErrors...)
+
+	id, validationErrors := validateID(r.PathValue("id"))
+	invalidParams = append(invalidParams, validationErrors...)
+
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+
+	// body is merged into current details of the locked booking
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body failed: %w", err)
+	}
+	booking, err := s.bookingProcessor.UpdateBookingDetails(r.Context(), id, func(details BookingDetails) (BookingDetails, error) {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&details); err != nil {
+			return BookingDetails{}, pkg.NewBadRequestError(pkg.InvalidParam{
+				Name:   "Body",
+				Reason: err.Error(),
+			})
+		}
+		if invalidParams := validateBookingDetails(details); len(invalidParams) > 0 {
+			return BookingDetails{}, pkg.NewBadRequestError(invalidParams...)
+		}
+		return details, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.withCapability(r.Context(), booking, capability)
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+func TestBookingDetails_LogValue(t *testing.T) {
+	details := BookingDetails{
+		Contact: Contact{
+			FullName:     "Jan Novák",
+			EmailAddress: "jan@example.com",
+			PhoneNumber:  "+420123456789",
+			Locale:       "cs-CZ",
+			Country:      "CZ",
+		},
+		Notes:             "wheelchair",
+		ResellerReference: "R-1",
+	}
+	for _, handler := range []func(buff *bytes.Buffer) slog.Handler{
+		func(buff *bytes.Buffer) slog.Handler {
+			return slog.NewJSONHandler(buff, &slog.HandlerOptions{ReplaceAttr: pkg.RedactAttr})
+		},
+		func(buff *bytes.Buffer) slog.Handler {
+			return slog.NewTextHandler(buff, &slog.HandlerOptions{ReplaceAttr: pkg.RedactAttr})
+		},
+	} {
+		buff := &bytes.Buffer{}
+		logger := slog.New(handler(buff))
+		logger.Info("booking", slog.Any("details", details), slog.Any("contact", details.Contact))
+
+		logged := buff.String()
+		for _, value := range []string{"Jan", "jan@example.com", "+420123456789", "cs-CZ", "CZ", "wheelchair"} {
+			if strings.Contains(logged, value) {
+				t.Fatalf("expected %q to be redacted, got %s", value, logged)
+			}
+		}
+		if !strings.Contains(logged, "[REDACTED]") || !strings.Contains(logged, "R-1") {
+			t.Fatalf("expected redacted details with reseller reference, got %s", logged)
+		}
+	}
+}
+
+func TestValidateBookingDetails(t *testing.T) {
+	tests := []struct {
+		name          string
+		details       BookingDetails
+		invalidParams []string
+	}{
+		{name: "empty"},
+		{
+			name: "complete",
+			details: BookingDetails{
+				Contact: Contact{
+					FullName:     "Jan Novák",
+					EmailAddress: "jan.novak@example.com",
+					PhoneNumber:  "+420123456789",
+					Locale:       "cs-CZ",
+					Country:      "CZ",
+				},
+				Notes:             "vegetarian lunch",
+				ResellerReference: "R-1234",
+			},
+		},
+		{name: "email with display name", details: BookingDetails{Contact: Contact{EmailAddress: "Jan <jan@example.com>"}}, invalidParams: []string{"contact.emailAddress"}},
+		{name: "email without domain", details: BookingDetails{Contact: Contact{EmailAddress: "jan@"}}, invalidParams: []string{"contact.emailAddress"}},
+		{name: "local phone", details: BookingDetails{Contact: Contact{PhoneNumber: "123 456 789"}}, invalidParams: []string{"contact.phoneNumber"}},
+		{name: "too long phone", details: BookingDetails{Contact: Contact{PhoneNumber: "+4201234567890123"}}, invalidParams: []string{"contact.phoneNumber"}},
+		{name: "locale with underscore", details: BookingDetails{Contact: Contact{Locale: "en_GB"}}, invalidParams: []string{"contact.locale"}},
+		{name: "lowercase country", details: BookingDetails{Contact: Contact{Country: "cz"}}, invalidParams: []string{"contact.country"}},
+		{
+			name:          "too long notes and reference",
+			details:       BookingDetails{Notes: strings.Repeat("a", maxNotesLength+1), ResellerReference: strings.Repeat("a", maxContactFieldLength+1)},
+			invalidParams: []string{"notes", "resellerReference"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidParams := validateBookingDetails(tt.details)
+			names := make([]string, 0, len(invalidParams))
+			for _, invalidParam := range invalidParams {
+				names = append(names, invalidParam.Name)
+			}
+			if !slices.Equal(names, tt.invalidParams) && len(names)+len(tt.invalidParams) > 0 {
+				t.Fatalf("expected invalid params %v, got %+v", tt.invalidParams, invalidParams)
+			}
+		})
+	}
+}
+
+func TestBookingRepository_BookingDetailsRows(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	productID := uuid.New()
+	availability := Availability{ID: uuid.New(), ProductID: productID, LocalDate: JSONTime(time.Now().UTC().Truncate(24 * time.Hour))}
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.products (id, name, capacity) VALUES ($1, 'product', 10)", productID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO ventrata.availability (id, product_id, date) VALUES ($1, $2, $3)", availability.ID, productID, time.Time(availability.LocalDate)); err != nil {
+		t.Fatal(err)
+	}
+	countRows := func(bookingID uuid.UUID) int {
+		t.Helper()
+		var count int
+		err := pool.QueryRow(
+			ctx,
+			`SELECT (SELECT count(*) FROM ventrata.booking_contacts WHERE booking_id = $1)
+	+ (SELECT count(*) FROM ventrata.booking_notes WHERE booking_id = $1)`,
+			bookingID,
+		).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	repository := NewBookingRepository(pool)
+	booking, err := repository.CreateBooking(ctx, availability, 1, BookingDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(booking.ID); count != 0 {
+		t.Fatalf("expected booking without details not to have detail rows, got %d", count)
+	}
+	if _, err := repository.UpdateBookingDetails(ctx, booking.ID, func(details BookingDetails) (BookingDetails, error) {
+		details.Notes = "late arrival"
+		return details, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(booking.ID); count != 1 {
+		t.Fatalf("expected only notes row to be stored, got %d rows", count)
+	}
+	if _, err := repository.UpdateBookingDetails(ctx, booking.ID, func(BookingDetails) (BookingDetails, error) {
+		return BookingDetails{}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(booking.ID); count != 0 {
+		t.Fatalf("expected cleared details to remove rows, got %d", count)
+	}
+}
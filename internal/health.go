@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/migrations"
+	"github.com/prathoss/hw/pkg"
+)
+
+const (
+	HealthStatusUp   = "UP"
+	HealthStatusDown = "DOWN"
+)
+
+// availabilityCoverageSlack tolerates the time between midnight and the daily availability generation
+const availabilityCoverageSlack = 1
+
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type Readiness struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type HealthProcessor interface {
+	Ping(ctx context.Context) error
+	// GetMigrationVersion returns version of the last applied migration and whether it failed midway
+	GetMigrationVersion(ctx context.Context) (uint, bool, error)
+}
+
+var _ HealthProcessor = &HealthRepository{}
+
+func NewHealthRepository(pool *pgxpool.Pool) *HealthRepository {
+	return &HealthRepository{
+		db: pool,
+	}
+}
+
+type HealthRepository struct {
+	db *pgxpool.Pool
+}
+
+func (h *HealthRepository) Ping(ctx context.Context) error {
+	return h.db.Ping(ctx)
+}
+
+func (h *HealthRepository) GetMigrationVersion(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := h.db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, fmt.Errorf("querying migration version failed: %w", err)
+	}
+	return uint(version), dirty, nil
+}
+
+// handleLiveness reports that process is able to serve requests, it does not check dependencies
+func (s *Server) handleLiveness(_ http.ResponseWriter, _ *http.Request) (any, error) {
+	return nil, nil
+}
+
+// handleReadiness reports whether all dependencies are ready for serving traffic
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := s.checkReadiness(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if readiness.Status == HealthStatusUp {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(readiness); err != nil {
+		s.logger.ErrorContext(r.Context(), "could not encode readiness", pkg.Err(err))
+	}
+}
+
+func (s *Server) checkReadiness(ctx context.Context) Readiness {
+	checks := []HealthCheck{
+		newHealthCheck("database", s.healthProcessor.Ping(ctx)),
+		newHealthCheck("migrations", s.checkMigrations(ctx)),
+	}
+	// servers embedded only by Handler do not run periodic jobs
+	if s.scheduler != nil && s.schedulerRunning.Load() {
+		checks = append(checks, newHealthCheck("scheduler", s.checkScheduler()))
+	}
+	checks = append(checks, newHealthCheck("availability", s.checkAvailabilityCoverage(ctx)))
+	readiness := Readiness{
+		Status: HealthStatusUp,
+		Checks: checks,
+	}
+	for _, check := range checks {
+		if check.Status != HealthStatusUp {
+			readiness.Status = HealthStatusDown
+		}
+	}
+	return readiness
+}
+
+func newHealthCheck(name string, err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{
+			Name:   name,
+			Status: HealthStatusDown,
+			Detail: err.Error(),
+		}
+	}
+	return HealthCheck{
+		Name:   name,
+		Status: HealthStatusUp,
+	}
+}
+
+func (s *Server) checkMigrations(ctx context.Context) error {
+	latestVersion, err := migrations.LatestVersion()
+	if err != nil {
+		return err
+	}
+	version, dirty, err := s.healthProcessor.GetMigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < latestVersion {
+		return fmt.Errorf("migrations are pending, applied version %d, latest version %d", version, latestVersion)
+	}
+	return nil
+}
+
+func (s *Server) checkScheduler() error {
+	entries := s.scheduler.Entries()
+	if len(entries) == 0 {
+		return errors.New("scheduler has no jobs")
+	}
+	for _, entry := range entries {
+		if entry.Next.IsZero() {
+			return fmt.Errorf("scheduler job %d is not scheduled", entry.ID)
+		}
+	}
+	return nil
+}
+
+func (s *Server) checkAvailabilityCoverage(ctx context.Context) error {
+	productIDs, err := s.availabilityProcessor.GetProductsNotCovered(ctx, s.clock.Now().UTC().Truncate(24*time.Hour), availabilityCoverageSlack)
+	if err != nil {
+		return err
+	}
+	if len(productIDs) > 0 {
+		ids := make([]string, 0, len(productIDs))
+		for _, productID := range productIDs {
+			ids = append(ids, productID.String())
+		}
+		return fmt.Errorf("availability does not cover sales window of products %s", strings.Join(ids, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+const (
+	// idempotencyKeyTTL is how long responses are kept for retries, expired key can be used for a new request
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyKeyLease is how long request holds its key, retry of the same request reclaims key after it,
+	// so that key is not stuck in progress after a crash or failed completion
+	idempotencyKeyLease = time.Minute
+	// idempotencyPurgeTimeout bounds purge, so that stuck purge does not hold its lock forever
+	idempotencyPurgeTimeout = time.Minute
+)
+
+const JobPurgeIdempotencyKeys = "purge-idempotency-keys"
+
+// idempotencyPurgeSchedule is cron expression of purging expired idempotency keys
+const idempotencyPurgeSchedule = "@hourly"
+
+type IdempotencyProcessor interface {
+	pkg.IdempotencyStore
+	// PurgeExpiredIdempotencyKeys deletes keys older than idempotencyKeyTTL, it returns number of deleted keys
+	PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+var _ IdempotencyProcessor = &IdempotencyRepository{}
+
+func NewIdempotencyRepository(pool *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db:    pool,
+		clock: systemClock{},
+	}
+}
+
+type IdempotencyRepository struct {
+	db    *pgxpool.Pool
+	clock Clock
+}
+
+func (i *IdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, key string, fingerprint string) (*pkg.IdempotentResponse, error) {
+	now := i.clock.Now().UTC()
+	tag, err := i.db.Exec(
+		ctx,
+		`INSERT INTO ventrata.idempotency_keys (key, fingerprint, created_at, locked_until) VALUES ($1, $2, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET fingerprint = excluded.fingerprint, status = NULL, content_type = NULL, body = NULL,
+			created_at = excluded.created_at, locked_until = excluded.locked_until
+		WHERE idempotency_keys.created_at < $4 - make_interval(secs => $3)
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until < $4 AND idempotency_keys.fingerprint = excluded.fingerprint)`,
+		key,
+		fingerprint,
+		idempotencyKeyTTL.Seconds(),
+		now,
+		now.Add(idempotencyKeyLease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reserving idempotency key failed: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var storedFingerprint string
+	var status *int
+	var contentType *string
+	var body []byte
+	err = i.db.QueryRow(
+		ctx,
+		"SELECT fingerprint, status, content_type, body FROM ventrata.idempotency_keys WHERE key = $1",
+		key,
+	).Scan(&storedFingerprint, &status, &contentType, &body)
+	if err != nil {
+		return nil, fmt.Errorf("querying idempotency key failed: %w", err)
+	}
+	if storedFingerprint != fingerprint {
+		return nil, pkg.NewDomainError(
+			pkg.ErrValidation,
+			pkg.ProblemCodeIdempotencyKeyReused,
+			"idempotency key was already used for a different request",
+			pkg.InvalidParam{Name: pkg.IdempotencyKeyHeader, Reason: "Key must be unique for every request"},
+		)
+	}
+	if status == nil {
+		return nil, pkg.NewDomainError(pkg.ErrConflict, pkg.ProblemCodeIdempotencyKeyInUse, "request with the idempotency key is still in progress")
+	}
+	response := &pkg.IdempotentResponse{
+		Status: *status,
+		Body:   body,
+	}
+	if contentType != nil {
+		response.ContentType = *contentType
+	}
+	return response, nil
+}
+
+func (i *IdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, key string, response pkg.IdempotentResponse) error {
+	_, err := i.db.Exec(
+		ctx,
+		"UPDATE ventrata.idempotency_keys SET status = $2, content_type = $3, body = $4 WHERE key = $1",
+		key,
+		response.Status,
+		response.ContentType,
+		response.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("storing idempotent response failed: %w", err)
+	}
+	return nil
+}
+
+func (i *IdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := i.db.Exec(ctx, "DELETE FROM ventrata.idempotency_keys WHERE key = $1 AND status IS NULL", key)
+	if err != nil {
+		return fmt.Errorf("releasing idempotency key failed: %w", err)
+	}
+	return nil
+}
+
+func (i *IdempotencyRepository) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := i.db.Exec(
+		ctx,
+		"DELETE FROM ventrata.idempotency_keys WHERE created_at < $2 - make_interval(secs => $1)",
+		idempotencyKeyTTL.Seconds(),
+		i.clock.Now().UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("purging idempotency keys failed: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys, so that stored responses are not kept longer than needed,
+// only one replica runs it at a time
+func (s *Server) PurgeIdempotencyKeys() {
+	if s.idempotencyProcessor == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyPurgeTimeout)
+	defer cancel()
+	var deleted int64
+	ran, err := s.runJob(ctx, JobPurgeIdempotencyKeys, idempotencyPurgeLockKey, func(ctx context.Context) (int64, error) {
+		var err error
+		deleted, err = s.idempotencyProcessor.PurgeExpiredIdempotencyKeys(ctx)
+		return deleted, err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to purge idempotency keys", pkg.Err(err))
+		return
+	}
+	if !ran {
+		s.logger.InfoContext(ctx, "idempotency keys are being purged by another replica")
+		return
+	}
+	s.logger.InfoContext(ctx, "idempotency keys purged", slog.Int64("deleted", deleted))
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+const JobCreateAvailabilities = "create-availabilities"
+
+// jobs are all jobs recording their runs
+var jobs = []string{JobCreateAvailabilities, JobBackfillAvailabilities, JobPurgeIdempotencyKeys}
+
+const defaultJobRunsLimit = 50
+
+// jobRunFinishTimeout bounds recording of the job result
+const jobRunFinishTimeout = 5 * time.Second
+
+type JobRun struct {
+	ID           uuid.UUID  `json:"id"`
+	Job          string     `json:"job"`
+	StartedAt    time.Time  `json:"startedAt"`
+	FinishedAt   *time.Time `json:"finishedAt"`
+	RowsInserted *int64     `json:"rowsInserted"`
+	Error        *string    `json:"error"`
+}
+
+type JobRunProcessor interface {
+	StartJobRun(ctx context.Context, job string, startedAt time.Time) (uuid.UUID, error)
+	// FinishJobRun records result of the run, jobErr is the error the job failed with
+	FinishJobRun(ctx context.Context, id uuid.UUID, finishedAt time.Time, rowsInserted int64, jobErr error) error
+	// ListJobRuns returns limit latest runs of job
+	ListJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error)
+}
+
+var _ JobRunProcessor = &JobRunRepository{}
+
+func NewJobRunRepository(pool *pgxpool.Pool) *JobRunRepository {
+	return &JobRunRepository{
+		db:    pool,
+		newID: uuid.New,
+	}
+}
+
+type JobRunRepository struct {
+	db    *pgxpool.Pool
+	newID IDGenerator
+}
+
+func (j *JobRunRepository) StartJobRun(ctx context.Context, job string, startedAt time.Time) (uuid.UUID, error) {
+	id := j.newID()
+	_, err := j.db.Exec(
+		ctx,
+		"INSERT INTO ventrata.job_runs (id, job, started_at) VALUES ($1, $2, $3)",
+		id,
+		job,
+		startedAt,
+	)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("inserting job run failed: %w", err)
+	}
+	return id, nil
+}
+
+func (j *JobRunRepository) FinishJobRun(ctx context.Context, id uuid.UUID, finishedAt time.Time, rowsInserted int64, jobErr error) error {
+	var errMessage *string
+	if jobErr != nil {
+		message := jobErr.Error()
+		errMessage = &message
+	}
+	_, err := j.db.Exec(
+		ctx,
+		"UPDATE ventrata.job_runs SET finished_at = $2, rows_inserted = $3, error = $4 WHERE id = $1",
+		id,
+		finishedAt,
+		rowsInserted,
+		errMessage,
+	)
+	if err != nil {
+		return fmt.Errorf("updating job run failed: %w", err)
+	}
+	return nil
+}
+
+func (j *JobRunRepository) ListJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	rows, err := j.db.Query(
+		ctx,
+		`SELECT id, job, started_at, finished_at, rows_inserted, error
+FROM ventrata.job_runs
+WHERE job = $1
+ORDER BY started_at DESC
+LIMIT $2`,
+		job,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying job runs failed: %w", err)
+	}
+	defer rows.Close()
+
+	jobRuns, err := pgx.CollectRows(rows, pgx.RowToStructByPos[JobRun])
+	if err != nil {
+		return nil, fmt.Errorf("scanning job runs failed: %w", err)
+	}
+	return jobRuns, nil
+}
+
+// runJob runs job only on the replica that wins lockKey and records the run, other replicas skip it
+func (s *Server) runJob(ctx context.Context, job string, lockKey int64, fn func(ctx context.Context) (int64, error)) (bool, error) {
+	return s.locker.TryWithLock(ctx, lockKey, func() error {
+		runID, err := s.jobRunProcessor.StartJobRun(ctx, job, s.clock.Now().UTC())
+		if err != nil {
+			return err
+		}
+		rowsInserted, jobErr := fn(ctx)
+		// run is recorded even when ctx was cancelled or timed out by the job, otherwise it would stay unfinished
+		finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobRunFinishTimeout)
+		defer cancel()
+		if err := s.jobRunProcessor.FinishJobRun(finishCtx, runID, s.clock.Now().UTC(), rowsInserted, jobErr); err != nil {
+			return errors.Join(jobErr, err)
+		}
+		return jobErr
+	})
+}
+
+func (s *Server) listJobRuns(_ http.ResponseWriter, r *http.Request) (any, error) {
+	job := r.PathValue("job")
+	if !slices.Contains(jobs, job) {
+		return nil, pkg.NewNotFoundError(fmt.Sprintf("job %s does not exist", job))
+	}
+
+	limit, invalidParams := parseLimitQuery(r.URL.Query(), defaultJobRunsLimit)
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+
+	return s.jobRunProcessor.ListJobRuns(r.Context(), job, limit)
+}
+
+// parseLimitQuery parses optional positive limit query parameter, defaultLimit is used when it is missing
+func parseLimitQuery(query url.Values, defaultLimit int) (int, []pkg.InvalidParam) {
+	limitStr := query.Get("limit")
+	if limitStr == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, []pkg.InvalidParam{{
+			Name:   "limit",
+			Reason: "Must be positive number",
+		}}
+	}
+	return limit, nil
+}
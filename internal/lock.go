@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+// keys of session advisory locks, they have to be unique across the database
+const (
+	migrationsLockKey       int64 = 4_851_001
+	availabilityJobLockKey  int64 = 4_851_002
+	idempotencyPurgeLockKey int64 = 4_851_003
+)
+
+// Locker provides locks shared by all replicas
+type Locker interface {
+	// TryWithLock runs fn only when nobody else holds lock key, it reports whether fn was run
+	TryWithLock(ctx context.Context, key int64, fn func() error) (bool, error)
+}
+
+var _ Locker = &AdvisoryLocker{}
+
+func NewAdvisoryLocker(pool *pgxpool.Pool) *AdvisoryLocker {
+	return &AdvisoryLocker{
+		db: pool,
+	}
+}
+
+// AdvisoryLocker elects single replica to run fn using Postgres session advisory locks
+type AdvisoryLocker struct {
+	db *pgxpool.Pool
+}
+
+func (a *AdvisoryLocker) TryWithLock(ctx context.Context, key int64, fn func() error) (bool, error) {
+	conn, err := a.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquiring lock connection failed: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		return false, fmt.Errorf("trying advisory lock %d failed: %w", key, err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer unlockAdvisoryLock(ctx, conn, key)
+
+	return true, fn()
+}
+
+// withAdvisoryLock runs fn while holding advisory lock key, it waits until other holders release the lock
+func withAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key int64, fn func() error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring lock connection failed: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return fmt.Errorf("acquiring advisory lock %d failed: %w", key, err)
+	}
+	defer unlockAdvisoryLock(ctx, conn, key)
+
+	return fn()
+}
+
+// unlockAdvisoryLock releases lock key held by session of conn, when it can not be released the connection is closed,
+// so that the lock is not kept by the pooled connection and other replicas can take it
+func unlockAdvisoryLock(ctx context.Context, conn *pgxpool.Conn, key int64) {
+	// lock has to be released even when ctx is done
+	var unlocked bool
+	err := conn.QueryRow(context.Background(), "SELECT pg_advisory_unlock($1)", key).Scan(&unlocked)
+	if err == nil && unlocked {
+		return
+	}
+	if err == nil {
+		err = fmt.Errorf("advisory lock %d was not held", key)
+	}
+	slog.ErrorContext(ctx, "releasing advisory lock failed, closing its connection", pkg.Err(err), slog.Int64("key", key))
+	// deferred Release of hijacked connection does nothing
+	if err := conn.Hijack().Close(context.Background()); err != nil {
+		slog.ErrorContext(ctx, "closing advisory lock connection failed", pkg.Err(err), slog.Int64("key", key))
+	}
+}
@@ -0,0 +1,728 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/migrations"
+	"github.com/prathoss/hw/pkg"
+)
+
+var _ ProductProcessor = &MemoryStore{}
+var _ AvailabilityProcessor = &MemoryStore{}
+var _ PricingProcessor = &MemoryStore{}
+var _ BookingProcessor = &MemoryStore{}
+var _ HealthProcessor = &MemoryStore{}
+var _ JobRunProcessor = &MemoryStore{}
+var _ Locker = &MemoryStore{}
+var _ PrivacyProcessor = &MemoryStore{}
+var _ IdempotencyProcessor = &MemoryStore{}
+
+// NewMemoryStore creates empty store, data is seeded with AddProduct, SetPricing and InsertAvailabilities
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		products:       map[uuid.UUID]Product{},
+		pricing:        map[uuid.UUID]map[string]memoryPricing{},
+		availabilities: map[uuid.UUID]memoryAvailability{},
+		bookings:       map[uuid.UUID]memoryBooking{},
+		listeners:      map[int]func(availabilityID uuid.UUID){},
+		jobRuns:        map[uuid.UUID]JobRun{},
+		locks:          map[int64]struct{}{},
+		privacyAudits:  make([]PrivacyAudit, 0),
+		idempotency:    map[string]memoryIdempotencyKey{},
+		clock:          systemClock{},
+		newID:          uuid.New,
+	}
+}
+
+// MemoryStore implements all processors of the server in memory with the same semantics as their repositories,
+// it is meant for tests and local development without database
+type MemoryStore struct {
+	mu             sync.RWMutex
+	products       map[uuid.UUID]Product
+	pricing        map[uuid.UUID]map[string]memoryPricing
+	pricingVersion int64
+	availabilities map[uuid.UUID]memoryAvailability
+	bookings       map[uuid.UUID]memoryBooking
+	listeners      map[int]func(availabilityID uuid.UUID)
+	nextListenerID int
+	jobRuns        map[uuid.UUID]JobRun
+	locks          map[int64]struct{}
+	privacyAudits  []PrivacyAudit
+	idempotency    map[string]memoryIdempotencyKey
+	// clock expires idempotency keys
+	clock Clock
+	newID IDGenerator
+}
+
+// setDependencies replaces ID generator and clock by the ones of server the store is injected to
+func (m *MemoryStore) setDependencies(newID IDGenerator, clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.newID = newID
+	m.clock = clock
+}
+
+type memoryPricing struct {
+	Pricing
+	// version is unique for every change of pricing as sequence of repository gives it
+	version int64
+}
+
+type memoryAvailability struct {
+	id        uuid.UUID
+	productID uuid.UUID
+	date      time.Time
+}
+
+type memoryIdempotencyKey struct {
+	fingerprint string
+	// response is nil while request is in progress
+	response  *pkg.IdempotentResponse
+	createdAt time.Time
+	// lockedUntil is end of the lease of in-progress request
+	lockedUntil time.Time
+}
+
+type memoryBooking struct {
+	id             uuid.UUID
+	availabilityID uuid.UUID
+	confirmed      bool
+	ticketIDs      []uuid.UUID
+	details        BookingDetails
+}
+
+// AddProduct stores product, product with the same ID is replaced
+func (m *MemoryStore) AddProduct(product Product) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.products[product.ID] = product
+}
+
+// SetPricing sets price of product in currency of pricing
+func (m *MemoryStore) SetPricing(productID uuid.UUID, pricing Pricing) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pricing[productID] == nil {
+		m.pricing[productID] = map[string]memoryPricing{}
+	}
+	m.pricingVersion++
+	m.pricing[productID][pricing.Currency] = memoryPricing{Pricing: pricing, version: m.pricingVersion}
+}
+
+func (m *MemoryStore) GetProduct(_ context.Context, id uuid.UUID) (Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	product, ok := m.products[id]
+	if !ok {
+		return Product{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeProductNotFound, fmt.Sprintf("product %s not found", id))
+	}
+	return product, nil
+}
+
+func (m *MemoryStore) ListProducts(_ context.Context, after uuid.UUID, limit int) ([]Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	products := make([]Product, 0, len(m.products))
+	for _, product := range m.products {
+		// IDs are compared bytewise as PostgreSQL compares them
+		if bytes.Compare(product.ID[:], after[:]) > 0 {
+			products = append(products, product)
+		}
+	}
+	slices.SortFunc(products, func(a, b Product) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	if len(products) > limit {
+		products = products[:limit]
+	}
+	return products, nil
+}
+
+func (m *MemoryStore) GetProducts(_ context.Context, ids []uuid.UUID) ([]Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	products := make([]Product, 0, len(ids))
+	for _, id := range ids {
+		if product, ok := m.products[id]; ok {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+func (m *MemoryStore) InsertAvailabilities(_ context.Context, availabilities []Availability) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// like the insert statement, either all availabilities are inserted or none
+	for _, availability := range availabilities {
+		if _, ok := m.products[availability.ProductID]; !ok {
+			return 0, fmt.Errorf("could not insert availability: product %s does not exist", availability.ProductID)
+		}
+		if _, ok := m.availabilities[availability.ID]; ok {
+			return 0, fmt.Errorf("could not insert availability: availability %s already exists", availability.ID)
+		}
+	}
+	var inserted int64
+	for _, availability := range availabilities {
+		date := toDate(time.Time(availability.LocalDate))
+		if m.hasAvailability(availability.ProductID, date) {
+			continue
+		}
+		m.availabilities[availability.ID] = memoryAvailability{
+			id:        availability.ID,
+			productID: availability.ProductID,
+			date:      date,
+		}
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (m *MemoryStore) GetAvailability(_ context.Context, productID uuid.UUID, day time.Time) ([]Availability, error) {
+	return m.findAvailabilities(func(availability memoryAvailability) bool {
+		return availability.productID == productID && availability.date.Equal(day)
+	}), nil
+}
+
+func (m *MemoryStore) GetAvailabilityTo(_ context.Context, productID uuid.UUID, from time.Time, to time.Time) ([]Availability, error) {
+	return m.findAvailabilities(func(availability memoryAvailability) bool {
+		return availability.productID == productID && !availability.date.Before(from) && !availability.date.After(to)
+	}), nil
+}
+
+func (m *MemoryStore) GetAvailabilityCalendar(ctx context.Context, productID uuid.UUID, from time.Time, to time.Time, currency string) ([]CalendarDay, error) {
+	availabilities, err := m.GetAvailabilityTo(ctx, productID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	var price *int
+	if pricing, ok := m.pricing[productID][currency]; ok {
+		price = &pricing.Price
+	}
+	m.mu.RUnlock()
+
+	days := make([]CalendarDay, 0, len(availabilities))
+	for _, availability := range availabilities {
+		day, err := newCalendarDay(productID, time.Time(availability.LocalDate), availability.Vacancies, price, currency)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func (m *MemoryStore) GetProductsAvailabilityTo(_ context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) ([]Availability, error) {
+	return m.findAvailabilities(func(availability memoryAvailability) bool {
+		return slices.Contains(productIDs, availability.productID) && !availability.date.Before(from) && !availability.date.After(to)
+	}), nil
+}
+
+func (m *MemoryStore) GetAvailabilityByID(_ context.Context, id uuid.UUID) (Availability, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	availability, ok := m.availabilities[id]
+	if !ok {
+		return Availability{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeAvailabilityNotFound, fmt.Sprintf("availability %s not found", id))
+	}
+	return m.toAvailability(availability), nil
+}
+
+func (m *MemoryStore) GetAvailabilityCoverage(_ context.Context, productIDs []uuid.UUID, from time.Time, to time.Time) (map[uuid.UUID]AvailabilityCoverage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	coverage := make(map[uuid.UUID]AvailabilityCoverage, len(productIDs))
+	for _, productID := range productIDs {
+		if _, ok := m.products[productID]; !ok {
+			continue
+		}
+		c := AvailabilityCoverage{Dates: []time.Time{}}
+		for _, availability := range m.availabilities {
+			if availability.productID != productID {
+				continue
+			}
+			if c.LatestDate == nil || availability.date.After(*c.LatestDate) {
+				latestDate := availability.date
+				c.LatestDate = &latestDate
+			}
+			if !availability.date.Before(from) && !availability.date.After(to) {
+				c.Dates = append(c.Dates, availability.date)
+			}
+		}
+		slices.SortFunc(c.Dates, func(a, b time.Time) int {
+			return a.Compare(b)
+		})
+		coverage[productID] = c
+	}
+	return coverage, nil
+}
+
+func (m *MemoryStore) GetProductsNotCovered(_ context.Context, today time.Time, slackDays int) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	productIDs := make([]uuid.UUID, 0)
+	for _, product := range m.products {
+		if product.SalesCutoff != nil && time.Time(*product.SalesCutoff).Before(toDate(today)) {
+			continue
+		}
+		coveredUntil := today.AddDate(0, 0, max(product.HorizonDays-slackDays, 0))
+		if product.SalesCutoff != nil && time.Time(*product.SalesCutoff).Before(coveredUntil) {
+			coveredUntil = time.Time(*product.SalesCutoff)
+		}
+		covered := false
+		for _, availability := range m.availabilities {
+			if availability.productID == product.ID && !availability.date.Before(coveredUntil) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			productIDs = append(productIDs, product.ID)
+		}
+	}
+	return productIDs, nil
+}
+
+func (m *MemoryStore) ListenAvailabilityChanges(ctx context.Context, notify func(availabilityID uuid.UUID)) error {
+	m.mu.Lock()
+	listenerID := m.nextListenerID
+	m.nextListenerID++
+	m.listeners[listenerID] = notify
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	delete(m.listeners, listenerID)
+	m.mu.Unlock()
+	return fmt.Errorf("waiting for availability change failed: %w", ctx.Err())
+}
+
+func (m *MemoryStore) GetPricedProducts(_ context.Context, products []Product, currency string) ([]PricedProduct, error) {
+	productIDs := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	return priceProducts(products, m.getPricingByProductID(productIDs, currency))
+}
+
+func (m *MemoryStore) GetPricedAvailabilities(_ context.Context, availabilities []Availability, currency string) ([]PricedAvailability, error) {
+	productIDs := uniqueProductIDs(availabilities, func(availability Availability) uuid.UUID { return availability.ProductID })
+	return priceAvailabilities(availabilities, m.getPricingByProductID(productIDs, currency))
+}
+
+func (m *MemoryStore) GetPricedBookings(_ context.Context, bookings []Booking, currency string) ([]PricedBooking, error) {
+	productIDs := uniqueProductIDs(bookings, func(booking Booking) uuid.UUID { return booking.ProductID })
+	return priceBookings(bookings, m.getPricingByProductID(productIDs, currency))
+}
+
+func (m *MemoryStore) CreateBooking(_ context.Context, availability Availability, units int, details BookingDetails) (Booking, error) {
+	m.mu.Lock()
+	stored, ok := m.availabilities[availability.ID]
+	if !ok {
+		m.mu.Unlock()
+		return Booking{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeAvailabilityNotFound, fmt.Sprintf("availability %s not found", availability.ID))
+	}
+	// vacancies are counted under the same lock the booking is stored under, so concurrent bookings can not overbook
+	if err := checkVacancies(m.toAvailability(stored), units); err != nil {
+		m.mu.Unlock()
+		return Booking{}, err
+	}
+	booking := memoryBooking{
+		id:             m.newID(),
+		availabilityID: availability.ID,
+		ticketIDs:      make([]uuid.UUID, 0, units),
+		details:        details,
+	}
+	for range units {
+		booking.ticketIDs = append(booking.ticketIDs, m.newID())
+	}
+	m.bookings[booking.id] = booking
+	result := m.toBooking(booking)
+	m.mu.Unlock()
+
+	m.notifyAvailabilityChanged(availability.ID)
+	return result, nil
+}
+
+func (m *MemoryStore) GetBooking(_ context.Context, bookingID uuid.UUID) (Booking, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	booking, ok := m.bookings[bookingID]
+	if !ok {
+		return Booking{}, newBookingNotFoundError(bookingID)
+	}
+	return m.toBooking(booking), nil
+}
+
+func (m *MemoryStore) ConfirmBooking(_ context.Context, bookingID uuid.UUID) (Booking, error) {
+	m.mu.Lock()
+	booking, ok := m.bookings[bookingID]
+	if !ok {
+		m.mu.Unlock()
+		return Booking{}, newBookingNotFoundError(bookingID)
+	}
+	if booking.confirmed {
+		m.mu.Unlock()
+		return Booking{}, newBookingAlreadyConfirmedError(bookingID)
+	}
+	booking.confirmed = true
+	m.bookings[bookingID] = booking
+	result := m.toBooking(booking)
+	m.mu.Unlock()
+
+	m.notifyAvailabilityChanged(booking.availabilityID)
+	return result, nil
+}
+
+func (m *MemoryStore) UpdateBookingDetails(_ context.Context, bookingID uuid.UUID, update func(details BookingDetails) (BookingDetails, error)) (Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	booking, ok := m.bookings[bookingID]
+	if !ok {
+		return Booking{}, newBookingNotFoundError(bookingID)
+	}
+	details, err := update(booking.details)
+	if err != nil {
+		return Booking{}, err
+	}
+	booking.details = details
+	m.bookings[bookingID] = booking
+	return m.toBooking(booking), nil
+}
+
+func (m *MemoryStore) ExportBookings(_ context.Context, emailAddress string, audit PrivacyAudit) ([]Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bookings := make([]Booking, 0)
+	for _, booking := range m.bookings {
+		if strings.EqualFold(booking.details.Contact.EmailAddress, emailAddress) {
+			bookings = append(bookings, m.toBooking(booking))
+		}
+	}
+	audit.Bookings = len(bookings)
+	m.addPrivacyAudit(audit)
+	return bookings, nil
+}
+
+func (m *MemoryStore) EraseContacts(_ context.Context, emailAddress string, audit PrivacyAudit) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bookingIDs := make([]uuid.UUID, 0)
+	for id, booking := range m.bookings {
+		if !strings.EqualFold(booking.details.Contact.EmailAddress, emailAddress) {
+			continue
+		}
+		booking.details.Contact = Contact{}
+		booking.details.Notes = ""
+		m.bookings[id] = booking
+		bookingIDs = append(bookingIDs, id)
+	}
+	if err := m.scrubIdempotentResponses(bookingIDs); err != nil {
+		return nil, err
+	}
+	audit.Bookings = len(bookingIDs)
+	m.addPrivacyAudit(audit)
+	return bookingIDs, nil
+}
+
+func (m *MemoryStore) ListPrivacyAudits(_ context.Context, limit int) ([]PrivacyAudit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// audits recorded at the same time are listed from the latest recorded
+	audits := slices.Clone(m.privacyAudits)
+	slices.Reverse(audits)
+	slices.SortStableFunc(audits, func(a PrivacyAudit, b PrivacyAudit) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return audits[:min(limit, len(audits))], nil
+}
+
+// scrubIdempotentResponses anonymises contact and notes in stored responses of bookings, m.mu must be held
+func (m *MemoryStore) scrubIdempotentResponses(bookingIDs []uuid.UUID) error {
+	erasedContact, err := json.Marshal(Contact{})
+	if err != nil {
+		return fmt.Errorf("encoding erased contact failed: %w", err)
+	}
+	for key, stored := range m.idempotency {
+		if stored.response == nil || !strings.HasPrefix(stored.response.ContentType, "application/json") {
+			continue
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(stored.response.Body, &body); err != nil {
+			continue
+		}
+		var id uuid.UUID
+		if err := json.Unmarshal(body["id"], &id); err != nil || !slices.Contains(bookingIDs, id) {
+			continue
+		}
+		body["contact"] = erasedContact
+		body["notes"] = json.RawMessage(`""`)
+		scrubbed, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding scrubbed response failed: %w", err)
+		}
+		response := *stored.response
+		response.Body = scrubbed
+		stored.response = &response
+		m.idempotency[key] = stored
+	}
+	return nil
+}
+
+// addPrivacyAudit records audit, m.mu must be held
+func (m *MemoryStore) addPrivacyAudit(audit PrivacyAudit) {
+	audit.ID = m.newID()
+	m.privacyAudits = append(m.privacyAudits, audit)
+}
+
+func (m *MemoryStore) ReserveIdempotencyKey(_ context.Context, key string, fingerprint string) (*pkg.IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now().UTC()
+	stored, ok := m.idempotency[key]
+	leaseEnded := ok && stored.response == nil && stored.lockedUntil.Before(now) && stored.fingerprint == fingerprint
+	if !ok || idempotencyKeyExpired(stored, now) || leaseEnded {
+		m.idempotency[key] = memoryIdempotencyKey{fingerprint: fingerprint, createdAt: now, lockedUntil: now.Add(idempotencyKeyLease)}
+		return nil, nil
+	}
+	if stored.fingerprint != fingerprint {
+		return nil, pkg.NewDomainError(
+			pkg.ErrValidation,
+			pkg.ProblemCodeIdempotencyKeyReused,
+			"idempotency key was already used for a different request",
+			pkg.InvalidParam{Name: pkg.IdempotencyKeyHeader, Reason: "Key must be unique for every request"},
+		)
+	}
+	if stored.response == nil {
+		return nil, pkg.NewDomainError(pkg.ErrConflict, pkg.ProblemCodeIdempotencyKeyInUse, "request with the idempotency key is still in progress")
+	}
+	response := *stored.response
+	response.Body = slices.Clone(stored.response.Body)
+	return &response, nil
+}
+
+func (m *MemoryStore) CompleteIdempotencyKey(_ context.Context, key string, response pkg.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.idempotency[key]
+	if !ok {
+		return nil
+	}
+	response.Body = slices.Clone(response.Body)
+	stored.response = &response
+	m.idempotency[key] = stored
+	return nil
+}
+
+func (m *MemoryStore) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.idempotency[key]; ok && stored.response == nil {
+		delete(m.idempotency, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) PurgeExpiredIdempotencyKeys(_ context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now().UTC()
+	var deleted int64
+	for key, stored := range m.idempotency {
+		if idempotencyKeyExpired(stored, now) {
+			delete(m.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// idempotencyKeyExpired reports whether key can be used for a new request, in the same way repository compares it
+func idempotencyKeyExpired(stored memoryIdempotencyKey, now time.Time) bool {
+	return stored.createdAt.Before(now.Add(-idempotencyKeyTTL))
+}
+
+// hasAvailability reports whether product has availability on date, m.mu must be held
+func (m *MemoryStore) hasAvailability(productID uuid.UUID, date time.Time) bool {
+	for _, availability := range m.availabilities {
+		if availability.productID == productID && availability.date.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) findAvailabilities(matches func(availability memoryAvailability) bool) []Availability {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	availabilities := make([]Availability, 0)
+	for _, availability := range m.availabilities {
+		if matches(availability) {
+			availabilities = append(availabilities, m.toAvailability(availability))
+		}
+	}
+	slices.SortFunc(availabilities, func(a, b Availability) int {
+		return time.Time(a.LocalDate).Compare(time.Time(b.LocalDate))
+	})
+	return availabilities
+}
+
+// toAvailability counts vacancies of availability from units of its bookings, m.mu must be held
+func (m *MemoryStore) toAvailability(availability memoryAvailability) Availability {
+	booked := 0
+	for _, booking := range m.bookings {
+		if booking.availabilityID == availability.id {
+			booked += len(booking.ticketIDs)
+		}
+	}
+	vacancies := m.products[availability.productID].Capacity - booked
+	return Availability{
+		ID:        availability.id,
+		LocalDate: JSONTime(availability.date),
+		Status:    availabilityStatus(vacancies),
+		Vacancies: vacancies,
+		Available: vacancies > 0,
+		ProductID: availability.productID,
+	}
+}
+
+// toBooking converts stored booking, m.mu must be held
+func (m *MemoryStore) toBooking(booking memoryBooking) Booking {
+	status := BookingStatusReserved
+	if booking.confirmed {
+		status = BookingStatusConfirmed
+	}
+	units := make([]Unit, 0, len(booking.ticketIDs))
+	for _, ticketID := range booking.ticketIDs {
+		unit := Unit{ID: ticketID}
+		if booking.confirmed {
+			ticket := confirmedTicketContent
+			unit.Ticket = &ticket
+		}
+		units = append(units, unit)
+	}
+	return Booking{
+		ID:             booking.id,
+		Status:         status,
+		ProductID:      m.availabilities[booking.availabilityID].productID,
+		AvailabilityID: booking.availabilityID,
+		Units:          units,
+		BookingDetails: booking.details,
+	}
+}
+
+func (m *MemoryStore) GetPricingVersion(_ context.Context, productID uuid.UUID, currency string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pricing[productID][currency].version, nil
+}
+
+func (m *MemoryStore) getPricingByProductID(productIDs []uuid.UUID, currency string) map[uuid.UUID]Pricing {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pricing := make(map[uuid.UUID]Pricing, len(productIDs))
+	for _, productID := range productIDs {
+		if p, ok := m.pricing[productID][currency]; ok {
+			pricing[productID] = p.Pricing
+		}
+	}
+	return pricing
+}
+
+func (m *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+// GetMigrationVersion reports the latest migration, memory store has no schema to migrate
+func (m *MemoryStore) GetMigrationVersion(_ context.Context) (uint, bool, error) {
+	version, err := migrations.LatestVersion()
+	return version, false, err
+}
+
+func (m *MemoryStore) StartJobRun(_ context.Context, job string, startedAt time.Time) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.newID()
+	m.jobRuns[id] = JobRun{ID: id, Job: job, StartedAt: startedAt}
+	return id, nil
+}
+
+func (m *MemoryStore) FinishJobRun(_ context.Context, id uuid.UUID, finishedAt time.Time, rowsInserted int64, jobErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobRun, ok := m.jobRuns[id]
+	if !ok {
+		return fmt.Errorf("job run %s does not exist", id)
+	}
+	jobRun.FinishedAt = &finishedAt
+	jobRun.RowsInserted = &rowsInserted
+	if jobErr != nil {
+		message := jobErr.Error()
+		jobRun.Error = &message
+	}
+	m.jobRuns[id] = jobRun
+	return nil
+}
+
+func (m *MemoryStore) ListJobRuns(_ context.Context, job string, limit int) ([]JobRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	jobRuns := make([]JobRun, 0)
+	for _, jobRun := range m.jobRuns {
+		if jobRun.Job == job {
+			jobRuns = append(jobRuns, jobRun)
+		}
+	}
+	slices.SortFunc(jobRuns, func(a, b JobRun) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	if len(jobRuns) > limit {
+		jobRuns = jobRuns[:limit]
+	}
+	return jobRuns, nil
+}
+
+func (m *MemoryStore) TryWithLock(_ context.Context, key int64, fn func() error) (bool, error) {
+	m.mu.Lock()
+	if _, ok := m.locks[key]; ok {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.locks[key] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.locks, key)
+		m.mu.Unlock()
+	}()
+	return true, fn()
+}
+
+// notifyAvailabilityChanged calls listeners the same way bookings trigger notifies them, m.mu must not be held
+func (m *MemoryStore) notifyAvailabilityChanged(availabilityID uuid.UUID) {
+	m.mu.RLock()
+	listeners := make([]func(availabilityID uuid.UUID), 0, len(m.listeners))
+	for _, listener := range m.listeners {
+		listeners = append(listeners, listener)
+	}
+	m.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(availabilityID)
+	}
+}
+
+// toDate truncates t to midnight UTC of its day, the same way days are stored in timestamptz date column of availability
+func toDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const metricsNamespace = "hw"
+
+var (
+	bookingsCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bookings_created_total",
+		Help:      "Number of created bookings.",
+	})
+	bookingsConfirmedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bookings_confirmed_total",
+		Help:      "Number of confirmed bookings.",
+	})
+	unitsSoldTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "units_sold_total",
+			Help:      "Number of units in confirmed bookings.",
+		},
+		[]string{"product_id"},
+	)
+	availabilityGenerationRunsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "availability_generation_runs_total",
+		Help:      "Number of availability generation runs.",
+	})
+	availabilityGenerationFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "availability_generation_failures_total",
+		Help:      "Number of failed availability generation runs.",
+	})
+)
+
+// newMetricsRegistry creates registry with runtime, HTTP, database pool and business metrics,
+// pool metrics are left out when pool is nil
+func newMetricsRegistry(pool *pgxpool.Pool) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	var poolErr error
+	if pool != nil {
+		poolErr = registry.Register(newPoolCollector(pool))
+	}
+	err := errors.Join(
+		registry.Register(collectors.NewGoCollector()),
+		registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})),
+		pkg.RegisterHttpMetrics(registry),
+		poolErr,
+		registry.Register(bookingsCreatedTotal),
+		registry.Register(bookingsConfirmedTotal),
+		registry.Register(unitsSoldTotal),
+		registry.Register(availabilityGenerationRunsTotal),
+		registry.Register(availabilityGenerationFailuresTotal),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+var _ prometheus.Collector = &poolCollector{}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	return &poolCollector{
+		pool: pool,
+		acquiredConns: prometheus.NewDesc(
+			"pgxpool_acquired_connections",
+			"Number of currently acquired connections in the pool.",
+			nil, nil,
+		),
+		idleConns: prometheus.NewDesc(
+			"pgxpool_idle_connections",
+			"Number of currently idle connections in the pool.",
+			nil, nil,
+		),
+		totalConns: prometheus.NewDesc(
+			"pgxpool_total_connections",
+			"Total number of resources currently in the pool.",
+			nil, nil,
+		),
+		maxConns: prometheus.NewDesc(
+			"pgxpool_max_connections",
+			"Maximum size of the pool.",
+			nil, nil,
+		),
+		acquireCount: prometheus.NewDesc(
+			"pgxpool_acquires_total",
+			"Number of successful acquires from the pool.",
+			nil, nil,
+		),
+		emptyAcquireCount: prometheus.NewDesc(
+			"pgxpool_empty_acquires_total",
+			"Number of successful acquires that waited for a connection because the pool was empty.",
+			nil, nil,
+		),
+		acquireDuration: prometheus.NewDesc(
+			"pgxpool_acquire_wait_seconds_total",
+			"Total duration of all successful acquires from the pool.",
+			nil, nil,
+		),
+	}
+}
+
+// poolCollector reads pgxpool stats on every scrape
+type poolCollector struct {
+	pool              *pgxpool.Pool
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquireCount      *prometheus.Desc
+	emptyAcquireCount *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+}
+
+func (p *poolCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- p.acquiredConns
+	descs <- p.idleConns
+	descs <- p.totalConns
+	descs <- p.maxConns
+	descs <- p.acquireCount
+	descs <- p.emptyAcquireCount
+	descs <- p.acquireDuration
+}
+
+func (p *poolCollector) Collect(metrics chan<- prometheus.Metric) {
+	stat := p.pool.Stat()
+	metrics <- prometheus.MustNewConstMetric(p.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	metrics <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	metrics <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	metrics <- prometheus.MustNewConstMetric(p.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	metrics <- prometheus.MustNewConstMetric(p.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(p.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(p.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	handler, err := newMemoryServer(t, NewMemoryStore()).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	productID := uuid.NewString()
+	for _, path := range []string{"/api/v1/products/" + productID, "/api/v1/products/" + uuid.NewString(), "/unknown/" + productID} {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+	}
+
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := string(body)
+	for _, series := range []string{
+		`http_requests_total{code="404",method="GET",route="GET /api/v1/products/{id}"}`,
+		`http_requests_total{code="404",method="GET",route="unmatched"}`,
+		`http_request_duration_seconds_count{method="GET",route="GET /api/v1/products/{id}"}`,
+		"hw_bookings_created_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(metrics, series) {
+			t.Fatalf("expected metrics to contain %s, got\n%s", series, metrics)
+		}
+	}
+	// requested paths must not become labels, every product would add new series
+	if strings.Contains(metrics, productID) {
+		t.Fatalf("expected path of request not to be used as label, got\n%s", metrics)
+	}
+	if strings.Contains(metrics, "pgxpool_") {
+		t.Fatal("expected server without pool to have no pool metrics")
+	}
+}
+
+func TestPoolCollector(t *testing.T) {
+	// pool connects lazily, its stats are collected without database
+	pool, err := pgxpool.New(context.Background(), "postgres://hw@127.0.0.1:1/hw?pool_max_conns=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	registry, err := newMetricsRegistry(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "pgxpool_") {
+			continue
+		}
+		metric := family.GetMetric()[0]
+		values[family.GetName()] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+	}
+	if len(values) != 7 {
+		t.Fatalf("expected 7 pool metrics, got %v", values)
+	}
+	if values["pgxpool_max_connections"] != 7 || values["pgxpool_acquired_connections"] != 0 {
+		t.Fatalf("expected stats of the pool, got %v", values)
+	}
+}
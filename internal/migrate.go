@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/prathoss/hw/migrations"
+)
+
+type MigrationStatus struct {
+	migrations.Migration
+	Applied bool
+}
+
+func NewMigrator(ctx context.Context, databaseDSN string) (*Migrator, error) {
+	pool, err := pgxpool.New(ctx, databaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("opening embedded migrations failed: %w", err)
+	}
+	driver, err := pgxmigrate.WithInstance(stdlib.OpenDBFromPool(pool), &pgxmigrate.Config{})
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("creating migration driver failed: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("creating migrator failed: %w", err)
+	}
+	return &Migrator{
+		db:      pool,
+		migrate: m,
+	}, nil
+}
+
+// Migrator applies migrations embedded in the binary, it is compatible with migrate CLI
+type Migrator struct {
+	db      *pgxpool.Pool
+	migrate *migrate.Migrate
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.migrate.Close()
+	m.db.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	if err := m.migrate.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("applying migrations failed: %w", err)
+	}
+	return nil
+}
+
+// UpLocked applies all pending migrations while holding advisory lock, so that concurrently starting replicas
+// wait for each other
+func (m *Migrator) UpLocked(ctx context.Context) error {
+	return withAdvisoryLock(ctx, m.db, migrationsLockKey, m.Up)
+}
+
+// Down reverts last steps migrations
+func (m *Migrator) Down(steps int) error {
+	if err := m.migrate.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("reverting migrations failed: %w", err)
+	}
+	return nil
+}
+
+// Version returns version of the last applied migration (0 when none was applied) and whether it failed midway
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reading migration version failed: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	version, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	embedded, err := migrations.List()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(embedded))
+	for _, migration := range embedded {
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   migration.Version <= version,
+		})
+	}
+	return statuses, nil
+}
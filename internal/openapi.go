@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"net/http"
+)
+
+// routeMux is http.ServeMux that remembers registered patterns, so that they can be compared with open api spec
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func newRouteMux() *routeMux {
+	return &routeMux{
+		ServeMux: http.NewServeMux(),
+	}
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
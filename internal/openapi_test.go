@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+func TestOpenAPI_DocumentsAllRoutes(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData(openApi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := make([]string, 0, 20)
+	for path, pathItem := range doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	s, err := NewServerWithProcessors(DefaultConfig(), memoryProcessors(NewMemoryStore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := make([]string, 0, 20)
+	for _, pattern := range s.newMux().patterns {
+		// only public API is documented
+		if _, path, _ := strings.Cut(pattern, " "); strings.HasPrefix(path, "/api/") {
+			registered = append(registered, pattern)
+		}
+	}
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("route %s is not documented", route)
+		}
+	}
+	for _, operation := range documented {
+		if !slices.Contains(registered, operation) {
+			t.Errorf("documented operation %s is not served", operation)
+		}
+	}
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.ValidateResponses = true
+	product := Product{ID: uuid.New(), Name: "Tour", Capacity: 10, HorizonDays: 365}
+	processors := memoryProcessors(NewMemoryStore())
+	processors.Product = stubProductProcessor{products: []Product{product}}
+	processors.Pricing = stubPricingProcessor{}
+	s, err := NewServerWithProcessors(cfg, processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := pkg.OpenAPIValidationHandler(openApi, cfg.Server.ValidateResponses, s.newMux())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		capability    string
+		status        int
+		invalidParams []string
+	}{
+		{name: "products match spec", method: http.MethodGet, path: "/api/v1/products", status: http.StatusOK},
+		{name: "priced products match spec", method: http.MethodGet, path: "/api/v1/products", capability: CapabilityPricing, status: http.StatusOK},
+		{name: "unknown capability", method: http.MethodGet, path: "/api/v1/products", capability: "discounts", status: http.StatusBadRequest, invalidParams: []string{"Capability"}},
+		{name: "malformed id", method: http.MethodGet, path: "/api/v1/products/42", status: http.StatusBadRequest, invalidParams: []string{"id"}},
+		{
+			name:          "invalid booking request",
+			method:        http.MethodPost,
+			path:          "/api/v1/bookings",
+			body:          `{"productId": "` + product.ID.String() + `", "units": 0}`,
+			status:        http.StatusBadRequest,
+			invalidParams: []string{"availabilityId", "units"},
+		},
+		{
+			name:   "availability of unknown product",
+			method: http.MethodPost,
+			path:   "/api/v1/availability",
+			body:   `{"productId": "` + uuid.NewString() + `", "localDate": "2024-06-01"}`,
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			if tt.capability != "" {
+				r.Header.Set("Capability", tt.capability)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if len(tt.invalidParams) == 0 {
+				return
+			}
+			var problem pkg.ValidationProblemDetail
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.invalidParams {
+				if !slices.ContainsFunc(problem.InvalidParams, func(param pkg.InvalidParam) bool { return param.Name == name }) {
+					t.Errorf("expected invalid param %s, got %+v", name, problem.InvalidParams)
+				}
+			}
+		})
+	}
+}
+
+type stubPricingProcessor struct {
+	PricingProcessor
+}
+
+func (s stubPricingProcessor) GetPricedProducts(_ context.Context, products []Product, currency string) ([]PricedProduct, error) {
+	pricedProducts := make([]PricedProduct, 0, len(products))
+	for _, product := range products {
+		pricedProducts = append(pricedProducts, PricedProduct{Product: product, Pricing: Pricing{Price: 1000, Currency: currency}})
+	}
+	return pricedProducts, nil
+}
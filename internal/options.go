@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// ServerOption configures server created by NewServer
+type ServerOption func(s *Server)
+
+// Clock tells current time, it is replaced in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// IDGenerator generates IDs of created entities
+type IDGenerator func() uuid.UUID
+
+// Scheduler runs jobs periodically, *cron.Cron implements it
+type Scheduler interface {
+	AddFunc(spec string, cmd func()) (cron.EntryID, error)
+	Entries() []cron.Entry
+	Start()
+	Stop() context.Context
+}
+
+// WithProcessors backs server by processors instead of database created from config, e.g. by MemoryStore,
+// all processors except Idempotency are required
+func WithProcessors(processors Processors) ServerOption {
+	return func(s *Server) {
+		s.productProcessor = processors.Product
+		s.pricingProcessor = processors.Pricing
+		s.availabilityProcessor = processors.Availability
+		s.bookingProcessor = processors.Booking
+		s.healthProcessor = processors.Health
+		s.jobRunProcessor = processors.JobRun
+		s.privacyProcessor = processors.Privacy
+		s.idempotencyProcessor = processors.Idempotency
+		s.locker = processors.Locker
+		s.injectedProcessors = true
+	}
+}
+
+// WithClock replaces system clock deciding today of availability generation, sales window of bookings
+// and expiry of idempotency keys
+func WithClock(clock Clock) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator replaces uuid.New for IDs generated by server and its repositories
+func WithIDGenerator(newID IDGenerator) ServerOption {
+	return func(s *Server) {
+		s.newID = newID
+	}
+}
+
+// WithLogger replaces default logger of the server
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithScheduler replaces cron scheduler started by Run
+func WithScheduler(scheduler Scheduler) ServerOption {
+	return func(s *Server) {
+		s.scheduler = scheduler
+	}
+}
+
+// WithoutScheduler disables periodic jobs, Run does not generate availabilities and readiness does not check scheduler
+func WithoutScheduler() ServerOption {
+	return func(s *Server) {
+		s.scheduler = nil
+	}
+}
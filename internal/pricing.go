@@ -39,6 +39,9 @@ type PricingProcessor interface {
 	GetPricedProducts(ctx context.Context, products []Product, currency string) ([]PricedProduct, error)
 	GetPricedAvailabilities(ctx context.Context, availabilities []Availability, currency string) ([]PricedAvailability, error)
 	GetPricedBookings(ctx context.Context, bookings []Booking, currency string) ([]PricedBooking, error)
+	// GetPricingVersion returns version of pricing of product in currency, it changes with every change of the pricing
+	// and it is 0 when product has no pricing in currency
+	GetPricingVersion(ctx context.Context, productID uuid.UUID, currency string) (int64, error)
 }
 
 var _ PricingProcessor = &PricingRepository{}
@@ -62,11 +65,94 @@ func (p *PricingRepository) GetPricedProducts(ctx context.Context, products []Pr
 	if err != nil {
 		return nil, err
 	}
+	return priceProducts(products, pricing)
+}
+
+func (p *PricingRepository) GetPricedAvailabilities(ctx context.Context, availabilities []Availability, currency string) ([]PricedAvailability, error) {
+	// availabilities of more products and days share pricing of their product
+	productIDs := uniqueProductIDs(availabilities, func(availability Availability) uuid.UUID { return availability.ProductID })
+	pricing, err := p.getPricingByProductId(ctx, productIDs, currency)
+	if err != nil {
+		return nil, err
+	}
+	return priceAvailabilities(availabilities, pricing)
+}
+
+func (p *PricingRepository) GetPricedBookings(ctx context.Context, bookings []Booking, currency string) ([]PricedBooking, error) {
+	productIDs := uniqueProductIDs(bookings, func(booking Booking) uuid.UUID { return booking.ProductID })
+	pricing, err := p.getPricingByProductId(ctx, productIDs, currency)
+	if err != nil {
+		return nil, err
+	}
+	return priceBookings(bookings, pricing)
+}
+
+func (p *PricingRepository) GetPricingVersion(ctx context.Context, productID uuid.UUID, currency string) (int64, error) {
+	var version int64
+	err := p.db.QueryRow(
+		ctx,
+		"SELECT coalesce(max(version), 0) FROM ventrata.pricing WHERE product_id = $1 AND currency = $2",
+		productID,
+		currency,
+	).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("querying pricing version failed: %w", err)
+	}
+	return version, nil
+}
+
+func (p *PricingRepository) getPricingByProductId(ctx context.Context, productIds []uuid.UUID, currency string) (map[uuid.UUID]Pricing, error) {
+	rows, err := p.db.Query(
+		ctx,
+		"SELECT product_id, price, currency FROM ventrata.pricing WHERE product_id = ANY($1) AND currency = $2",
+		productIds,
+		currency,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying pricing by product ids failed: %w", err)
+	}
+	defer rows.Close()
+
+	pricing := map[uuid.UUID]Pricing{}
+	for rows.Next() {
+		var productId uuid.UUID
+		var p Pricing
+		err := rows.Scan(&productId, &p.Price, &p.Currency)
+		if err != nil {
+			return nil, err
+		}
+		pricing[productId] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pricing, nil
+}
+
+// uniqueProductIDs returns distinct product IDs of items in order of their first occurrence,
+// pricing is looked up once per product
+func uniqueProductIDs[T any](items []T, productID func(item T) uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]struct{}, len(items))
+	productIDs := make([]uuid.UUID, 0)
+	for _, item := range items {
+		id := productID(item)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		productIDs = append(productIDs, id)
+	}
+	return productIDs
+}
+
+// priceProducts extends products with pricing of their product, every product must have pricing
+func priceProducts(products []Product, pricing map[uuid.UUID]Pricing) ([]PricedProduct, error) {
 	pricedProducts := make([]PricedProduct, 0, len(products))
 	for _, product := range products {
 		pricing, ok := pricing[product.ID]
 		if !ok {
-			return nil, pkg.NewNotFoundError(fmt.Sprintf("could not find pricing for product %s", product.ID))
+			return nil, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodePricingNotFound, fmt.Sprintf("could not find pricing for product %s", product.ID))
 		}
 		pricedProducts = append(pricedProducts, PricedProduct{
 			Product: product,
@@ -76,24 +162,12 @@ func (p *PricingRepository) GetPricedProducts(ctx context.Context, products []Pr
 	return pricedProducts, nil
 }
 
-func (p *PricingRepository) GetPricedAvailabilities(ctx context.Context, availabilities []Availability, currency string) ([]PricedAvailability, error) {
-	productIDsMap := map[uuid.UUID]struct{}{}
-	for _, availability := range availabilities {
-		productIDsMap[availability.ProductID] = struct{}{}
-	}
-	productIDs := make([]uuid.UUID, 0, len(productIDsMap))
-	for productID := range productIDsMap {
-		productIDs = append(productIDs, productID)
-	}
-	pricing, err := p.getPricingByProductId(ctx, productIDs, currency)
-	if err != nil {
-		return nil, err
-	}
+func priceAvailabilities(availabilities []Availability, pricing map[uuid.UUID]Pricing) ([]PricedAvailability, error) {
 	pricedAvailabilities := make([]PricedAvailability, 0, len(availabilities))
 	for _, availability := range availabilities {
 		pricing, ok := pricing[availability.ProductID]
 		if !ok {
-			return nil, pkg.NewNotFoundError(fmt.Sprintf("could not find pricing for availability %s", availability.ID))
+			return nil, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodePricingNotFound, fmt.Sprintf("could not find pricing for availability %s", availability.ID))
 		}
 		pricedAvailabilities = append(pricedAvailabilities, PricedAvailability{
 			Availability: availability,
@@ -103,26 +177,13 @@ func (p *PricingRepository) GetPricedAvailabilities(ctx context.Context, availab
 	return pricedAvailabilities, nil
 }
 
-func (p *PricingRepository) GetPricedBookings(ctx context.Context, bookings []Booking, currency string) ([]PricedBooking, error) {
-	productIdsMap := map[uuid.UUID]struct{}{}
-	for _, booking := range bookings {
-		productIdsMap[booking.ProductID] = struct{}{}
-	}
-	productIDs := make([]uuid.UUID, 0, len(productIdsMap))
-	for productID := range productIdsMap {
-		productIDs = append(productIDs, productID)
-	}
-
-	pricing, err := p.getPricingByProductId(ctx, productIDs, currency)
-	if err != nil {
-		return nil, err
-	}
-
+// priceBookings prices every unit of bookings, price of booking is the sum of its units
+func priceBookings(bookings []Booking, pricing map[uuid.UUID]Pricing) ([]PricedBooking, error) {
 	pricedBookings := make([]PricedBooking, 0, len(bookings))
 	for _, booking := range bookings {
 		pricing, ok := pricing[booking.ProductID]
 		if !ok {
-			return nil, pkg.NewNotFoundError(fmt.Sprintf("could not find pricing for booking %s", booking.ID))
+			return nil, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodePricingNotFound, fmt.Sprintf("could not find pricing for booking %s", booking.ID))
 		}
 
 		pricedUnits := make([]PricedUnit, 0, len(booking.Units))
@@ -142,35 +203,5 @@ func (p *PricingRepository) GetPricedBookings(ctx context.Context, bookings []Bo
 			},
 		})
 	}
-
 	return pricedBookings, nil
 }
-
-func (p *PricingRepository) getPricingByProductId(ctx context.Context, productIds []uuid.UUID, currency string) (map[uuid.UUID]Pricing, error) {
-	rows, err := p.db.Query(
-		ctx,
-		"SELECT product_id, price, currency FROM ventrata.pricing WHERE product_id = ANY($1) AND currency = $2",
-		productIds,
-		currency,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("querying pricing by product ids failed: %w", err)
-	}
-	defer rows.Close()
-
-	pricing := map[uuid.UUID]Pricing{}
-	for rows.Next() {
-		var productId uuid.UUID
-		var p Pricing
-		err := rows.Scan(&productId, &p.Price, &p.Currency)
-		if err != nil {
-			return nil, err
-		}
-		pricing[productId] = p
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return pricing, nil
-}
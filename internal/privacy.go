@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/prathoss/hw/pkg"
+)
+
+const (
+	PrivacyActionExport  = "EXPORT"
+	PrivacyActionErasure = "ERASURE"
+)
+
+const (
+	PrivacySourceAdminAPI = "admin-api"
+	PrivacySourceCLI      = "cli"
+)
+
+const defaultPrivacyAuditsLimit = 50
+
+// PrivacyAudit records handled data-subject request, the email address itself is not kept so that erased
+// addresses do not stay in the audit trail
+type PrivacyAudit struct {
+	ID     uuid.UUID `json:"id"`
+	Action string    `json:"action"`
+	// Subject is keyed hash of normalized email address, requests of the same subject have the same hash
+	Subject   string    `json:"subject"`
+	Source    string    `json:"source"`
+	Bookings  int       `json:"bookings"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DataSubjectRequest identifies the data subject by email address of booking contact
+type DataSubjectRequest struct {
+	EmailAddress string `json:"emailAddress"`
+}
+
+// DataSubjectExport are all bookings and their tickets of the data subject
+type DataSubjectExport struct {
+	EmailAddress string    `json:"emailAddress"`
+	ExportedAt   time.Time `json:"exportedAt"`
+	Bookings     []Booking `json:"bookings"`
+}
+
+// DataSubjectErasure lists bookings whose contact was anonymised
+type DataSubjectErasure struct {
+	ErasedAt   time.Time   `json:"erasedAt"`
+	BookingIDs []uuid.UUID `json:"bookingIds"`
+}
+
+type PrivacyProcessor interface {
+	// ExportBookings returns bookings whose contact has emailAddress and records the export by audit
+	ExportBookings(ctx context.Context, emailAddress string, audit PrivacyAudit) ([]Booking, error)
+	// EraseContacts anonymises contact and notes of bookings whose contact has emailAddress and records the erasure
+	// by audit, bookings, tickets and reseller references are kept for accounting
+	EraseContacts(ctx context.Context, emailAddress string, audit PrivacyAudit) ([]uuid.UUID, error)
+	// ListPrivacyAudits returns limit latest audit records
+	ListPrivacyAudits(ctx context.Context, limit int) ([]PrivacyAudit, error)
+}
+
+var _ PrivacyProcessor = &BookingRepository{}
+
+func (b *BookingRepository) ExportBookings(ctx context.Context, emailAddress string, audit PrivacyAudit) ([]Booking, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin booking export transaction failed: %w", err)
+	}
+	defer rollbackPrivacyTx(ctx, tx)
+
+	rows, err := tx.Query(ctx, baseBookingQuery+" WHERE lower(c.email_address) = lower($1)", emailAddress)
+	if err != nil {
+		return nil, fmt.Errorf("querying bookings by email address failed: %w", err)
+	}
+	bookings, err := b.scanBookings(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	audit.Bookings = len(bookings)
+	if err := b.insertPrivacyAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit booking export transaction failed: %w", err)
+	}
+	return bookings, nil
+}
+
+func (b *BookingRepository) EraseContacts(ctx context.Context, emailAddress string, audit PrivacyAudit) ([]uuid.UUID, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin contact erasure transaction failed: %w", err)
+	}
+	defer rollbackPrivacyTx(ctx, tx)
+
+	rows, err := tx.Query(
+		ctx,
+		`UPDATE ventrata.booking_contacts
+SET full_name = '', email_address = '', phone_number = '', locale = '', country = ''
+WHERE lower(email_address) = lower($1)
+RETURNING booking_id`,
+		emailAddress,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erasing booking contacts failed: %w", err)
+	}
+	bookingIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("scanning erased bookings failed: %w", err)
+	}
+	// notes are free text written by the guest, they might contain personal data as well
+	if _, err := tx.Exec(ctx, "UPDATE ventrata.booking_notes SET notes = '' WHERE booking_id = ANY($1)", bookingIDs); err != nil {
+		return nil, fmt.Errorf("erasing booking notes failed: %w", err)
+	}
+	if err := scrubIdempotentResponses(ctx, tx, bookingIDs); err != nil {
+		return nil, err
+	}
+	audit.Bookings = len(bookingIDs)
+	if err := b.insertPrivacyAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit contact erasure transaction failed: %w", err)
+	}
+	return bookingIDs, nil
+}
+
+func (b *BookingRepository) ListPrivacyAudits(ctx context.Context, limit int) ([]PrivacyAudit, error) {
+	rows, err := b.db.Query(
+		ctx,
+		`SELECT id, action, subject, source, bookings, created_at
+FROM ventrata.privacy_audits
+ORDER BY created_at DESC
+LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying privacy audits failed: %w", err)
+	}
+	defer rows.Close()
+
+	audits, err := pgx.CollectRows(rows, pgx.RowToStructByPos[PrivacyAudit])
+	if err != nil {
+		return nil, fmt.Errorf("scanning privacy audits failed: %w", err)
+	}
+	return audits, nil
+}
+
+func (b *BookingRepository) insertPrivacyAudit(ctx context.Context, tx pgx.Tx, audit PrivacyAudit) error {
+	_, err := tx.Exec(
+		ctx,
+		"INSERT INTO ventrata.privacy_audits (id, action, subject, source, bookings, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		b.newID(),
+		audit.Action,
+		audit.Subject,
+		audit.Source,
+		audit.Bookings,
+		audit.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting privacy audit failed: %w", err)
+	}
+	return nil
+}
+
+// scrubIdempotentResponses anonymises contact and notes in stored responses of bookings, so that replays
+// do not return erased data, status and the rest of the body are kept so that retries still see the same booking
+func scrubIdempotentResponses(ctx context.Context, tx pgx.Tx, bookingIDs []uuid.UUID) error {
+	if len(bookingIDs) == 0 {
+		return nil
+	}
+	// reseller reference is kept for accounting like in booking_notes
+	erased, err := json.Marshal(map[string]any{"contact": Contact{}, "notes": ""})
+	if err != nil {
+		return fmt.Errorf("encoding erased details failed: %w", err)
+	}
+	// keys expire after idempotencyKeyTTL and are purged, so the scan stays small, bodies that are not json
+	// objects are skipped by CASE because conditions joined by AND are not evaluated in order
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE ventrata.idempotency_keys
+SET body = convert_to((convert_from(body, 'UTF8')::jsonb || $2::jsonb)::text, 'UTF8')
+WHERE CASE
+	WHEN status IS NULL OR coalesce(content_type, '') NOT LIKE 'application/json%' THEN false
+	WHEN jsonb_typeof(convert_from(body, 'UTF8')::jsonb) <> 'object' THEN false
+	ELSE convert_from(body, 'UTF8')::jsonb ->> 'id' = ANY($1::uuid[]::text[])
+END`,
+		bookingIDs,
+		string(erased),
+	)
+	if err != nil {
+		return fmt.Errorf("scrubbing idempotent responses failed: %w", err)
+	}
+	return nil
+}
+
+// rollbackPrivacyTx rolls back tx unless it was committed
+func rollbackPrivacyTx(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		slog.ErrorContext(ctx, "rolling back privacy transaction failed", pkg.Err(err))
+	}
+}
+
+// errPrivacySubjectKeyMissing rejects data-subject requests, their audit trail could not be kept without the key
+var errPrivacySubjectKeyMissing = errors.New("privacy.subjectKey is not configured")
+
+// privacySubject identifies data subject in audit trail without keeping the email address, hash is keyed
+// so that the trail cannot be matched against a list of known addresses
+func privacySubject(key string, emailAddress string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.ToLower(emailAddress)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// privacySubject identifies data subject by key of the server
+func (s *Server) privacySubject(emailAddress string) (string, error) {
+	if s.config.Privacy.SubjectKey == "" {
+		return "", pkg.NewServiceUnavailableError(errPrivacySubjectKeyMissing)
+	}
+	return privacySubject(s.config.Privacy.SubjectKey, emailAddress), nil
+}
+
+func validateDataSubject(emailAddress string) error {
+	if !isEmailAddress(emailAddress) {
+		return pkg.NewBadRequestError(pkg.InvalidParam{
+			Name:   "emailAddress",
+			Reason: "Must be valid email address",
+		})
+	}
+	return nil
+}
+
+// ExportDataSubject returns all bookings and tickets of contact with emailAddress, export is recorded in audit trail
+func (s *Server) ExportDataSubject(ctx context.Context, emailAddress string, source string) (DataSubjectExport, error) {
+	if err := validateDataSubject(emailAddress); err != nil {
+		return DataSubjectExport{}, err
+	}
+	subject, err := s.privacySubject(emailAddress)
+	if err != nil {
+		return DataSubjectExport{}, err
+	}
+	now := s.clock.Now().UTC()
+	bookings, err := s.privacyProcessor.ExportBookings(ctx, emailAddress, PrivacyAudit{
+		Action:    PrivacyActionExport,
+		Subject:   subject,
+		Source:    source,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return DataSubjectExport{}, err
+	}
+	slices.SortFunc(bookings, func(a Booking, b Booking) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	s.logger.InfoContext(ctx, "bookings of data subject exported", slog.String("subject", subject), slog.Int("bookings", len(bookings)))
+	return DataSubjectExport{
+		EmailAddress: emailAddress,
+		ExportedAt:   now,
+		Bookings:     bookings,
+	}, nil
+}
+
+// EraseDataSubject anonymises contacts of bookings with emailAddress, erasure is recorded in audit trail
+func (s *Server) EraseDataSubject(ctx context.Context, emailAddress string, source string) (DataSubjectErasure, error) {
+	if err := validateDataSubject(emailAddress); err != nil {
+		return DataSubjectErasure{}, err
+	}
+	subject, err := s.privacySubject(emailAddress)
+	if err != nil {
+		return DataSubjectErasure{}, err
+	}
+	now := s.clock.Now().UTC()
+	bookingIDs, err := s.privacyProcessor.EraseContacts(ctx, emailAddress, PrivacyAudit{
+		Action:    PrivacyActionErasure,
+		Subject:   subject,
+		Source:    source,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return DataSubjectErasure{}, err
+	}
+	s.logger.InfoContext(ctx, "contacts of data subject erased", slog.String("subject", subject), slog.Int("bookings", len(bookingIDs)))
+	return DataSubjectErasure{
+		ErasedAt:   now,
+		BookingIDs: bookingIDs,
+	}, nil
+}
+
+// ListPrivacyAudits returns limit latest records of the audit trail
+func (s *Server) ListPrivacyAudits(ctx context.Context, limit int) ([]PrivacyAudit, error) {
+	return s.privacyProcessor.ListPrivacyAudits(ctx, limit)
+}
+
+// decodeDataSubjectRequest reads the email address from body, so that it does not end up in access logs
+func decodeDataSubjectRequest(r *http.Request) (DataSubjectRequest, error) {
+	var request DataSubjectRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return DataSubjectRequest{}, pkg.NewBadRequestError(pkg.InvalidParam{
+			Name:   "Body",
+			Reason: err.Error(),
+		})
+	}
+	return request, nil
+}
+
+func (s *Server) exportDataSubjectHandler(_ http.ResponseWriter, r *http.Request) (any, error) {
+	request, err := decodeDataSubjectRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExportDataSubject(r.Context(), request.EmailAddress, PrivacySourceAdminAPI)
+}
+
+func (s *Server) eraseDataSubjectHandler(_ http.ResponseWriter, r *http.Request) (any, error) {
+	request, err := decodeDataSubjectRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.EraseDataSubject(r.Context(), request.EmailAddress, PrivacySourceAdminAPI)
+}
+
+func (s *Server) listPrivacyAuditsHandler(_ http.ResponseWriter, r *http.Request) (any, error) {
+	limit, invalidParams := parseLimitQuery(r.URL.Query(), defaultPrivacyAuditsLimit)
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+	return s.ListPrivacyAudits(r.Context(), limit)
+}
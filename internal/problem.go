@@ -0,0 +1,16 @@
+package internal
+
+// codes of domain problems, every code is documented in docs/problems.md
+const (
+	ProblemCodeProductNotFound             = "PRODUCT_NOT_FOUND"
+	ProblemCodeAvailabilityNotFound        = "AVAILABILITY_NOT_FOUND"
+	ProblemCodeBookingNotFound             = "BOOKING_NOT_FOUND"
+	ProblemCodePricingNotFound             = "PRICING_NOT_FOUND"
+	ProblemCodeAvailabilitySoldOut         = "AVAILABILITY_SOLD_OUT"
+	ProblemCodeInsufficientVacancies       = "INSUFFICIENT_VACANCIES"
+	ProblemCodeAvailabilityExpired         = "AVAILABILITY_EXPIRED"
+	ProblemCodeOutsideSalesWindow          = "OUTSIDE_SALES_WINDOW"
+	ProblemCodeProductAvailabilityMismatch = "PRODUCT_AVAILABILITY_MISMATCH"
+	ProblemCodeBookingAlreadyConfirmed     = "BOOKING_ALREADY_CONFIRMED"
+	ProblemCodeGenerationRunning           = "AVAILABILITY_GENERATION_RUNNING"
+)
@@ -0,0 +1,760 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
+)
+
+// conformanceBackend is a set of processors sharing the same data
+type conformanceBackend struct {
+	products     ProductProcessor
+	availability AvailabilityProcessor
+	pricing      PricingProcessor
+	bookings     BookingProcessor
+	privacy      PrivacyProcessor
+	idempotency  IdempotencyProcessor
+	// clock expires idempotency keys
+	clock *testClock
+	// addProduct stores product with its pricing
+	addProduct func(t *testing.T, product Product, pricing Pricing)
+}
+
+func newMemoryBackend(_ *testing.T) conformanceBackend {
+	store := NewMemoryStore()
+	clock := newTestClock(conformanceToday)
+	store.clock = clock
+	return conformanceBackend{
+		products:     store,
+		availability: store,
+		pricing:      store,
+		bookings:     store,
+		privacy:      store,
+		idempotency:  store,
+		clock:        clock,
+		addProduct: func(_ *testing.T, product Product, pricing Pricing) {
+			store.AddProduct(product)
+			store.SetPricing(product.ID, pricing)
+		},
+	}
+}
+
+func newPostgresBackend(t *testing.T) conformanceBackend {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	pool, err := pgxpool.New(context.Background(), pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	bookingRepository := NewBookingRepository(pool)
+	clock := newTestClock(conformanceToday)
+	idempotencyRepository := NewIdempotencyRepository(pool)
+	idempotencyRepository.clock = clock
+	return conformanceBackend{
+		products:     NewProductRepository(pool),
+		availability: NewAvailabilityRepository(pool),
+		pricing:      NewPricingRepository(pool),
+		bookings:     bookingRepository,
+		privacy:      bookingRepository,
+		idempotency:  idempotencyRepository,
+		clock:        clock,
+		addProduct: func(t *testing.T, product Product, pricing Pricing) {
+			ctx := context.Background()
+			var salesCutoff *time.Time
+			if product.SalesCutoff != nil {
+				cutoff := time.Time(*product.SalesCutoff)
+				salesCutoff = &cutoff
+			}
+			_, err := pool.Exec(
+				ctx,
+				"INSERT INTO ventrata.products (id, name, capacity, horizon_days, lead_time_minutes, sales_cutoff) VALUES ($1, $2, $3, $4, $5, $6)",
+				product.ID, product.Name, product.Capacity, product.HorizonDays, product.LeadTimeMinutes, salesCutoff,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = pool.Exec(ctx, "INSERT INTO ventrata.pricing (product_id, price, currency) VALUES ($1, $2, $3)", product.ID, pricing.Price, pricing.Currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+// TestProcessorConformance runs the same cases against every backend, so that in-memory processors keep
+// semantics of repositories
+func TestProcessorConformance(t *testing.T) {
+	backends := []struct {
+		name  string
+		setup func(t *testing.T) conformanceBackend
+	}{
+		{name: "memory", setup: newMemoryBackend},
+		{name: "postgres", setup: newPostgresBackend},
+	}
+	cases := []struct {
+		name string
+		test func(t *testing.T, b conformanceBackend)
+	}{
+		{name: "products", test: testConformanceProducts},
+		{name: "availabilities", test: testConformanceAvailabilities},
+		{name: "availability coverage", test: testConformanceCoverage},
+		{name: "bookings", test: testConformanceBookings},
+		{name: "booking details", test: testConformanceBookingDetails},
+		{name: "data-subject requests", test: testConformanceDataSubject},
+		{name: "idempotency keys", test: testConformanceIdempotency},
+		{name: "no overbooking", test: testConformanceOverbooking},
+		{name: "pricing", test: testConformancePricing},
+		{name: "calendar", test: testConformanceCalendar},
+		{name: "multi-product availabilities", test: testConformanceProductsAvailabilities},
+		{name: "availability changes", test: testConformanceAvailabilityChanges},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			b := backend.setup(t)
+			for _, c := range cases {
+				t.Run(c.name, func(t *testing.T) {
+					c.test(t, b)
+				})
+			}
+		})
+	}
+}
+
+var conformanceToday = time.Now().UTC().Truncate(24 * time.Hour)
+
+// seedProduct adds product with capacity priced at 1000 EUR and availabilities on days after today
+func seedProduct(t *testing.T, b conformanceBackend, capacity int, days ...int) (Product, []Availability) {
+	t.Helper()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: capacity, HorizonDays: 365}
+	b.addProduct(t, product, Pricing{Price: 1000, Currency: "EUR"})
+	availabilities := make([]Availability, 0, len(days))
+	for _, day := range days {
+		availabilities = append(availabilities, Availability{
+			ID:        uuid.New(),
+			ProductID: product.ID,
+			LocalDate: JSONTime(conformanceToday.AddDate(0, 0, day)),
+		})
+	}
+	if _, err := b.availability.InsertAvailabilities(context.Background(), availabilities); err != nil {
+		t.Fatal(err)
+	}
+	return product, availabilities
+}
+
+func expectDomainError(t *testing.T, err error, kind error, code string) {
+	t.Helper()
+	var domainErr *pkg.DomainError
+	if !errors.Is(err, kind) || !errors.As(err, &domainErr) || domainErr.Code != code {
+		t.Fatalf("expected %s error, got %v", code, err)
+	}
+}
+
+func testConformanceProducts(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	cutoff := JSONTime(conformanceToday.AddDate(0, 0, 30))
+	product := Product{ID: uuid.New(), Name: "tour", Capacity: 5, HorizonDays: 60, LeadTimeMinutes: 90, SalesCutoff: &cutoff}
+	b.addProduct(t, product, Pricing{Price: 1000, Currency: "EUR"})
+
+	got, err := b.products.GetProduct(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != product.Name || got.Capacity != product.Capacity || got.HorizonDays != product.HorizonDays ||
+		got.LeadTimeMinutes != product.LeadTimeMinutes || !time.Time(*got.SalesCutoff).Equal(time.Time(cutoff)) {
+		t.Fatalf("expected %+v, got %+v", product, got)
+	}
+	products, err := b.products.ListProducts(ctx, uuid.Nil, productPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(products, func(p Product) bool { return p.ID == product.ID }) {
+		t.Fatalf("expected products to contain %s", product.ID)
+	}
+	// pages follow each other in order of IDs as both backends compare them
+	first, err := b.products.ListProducts(ctx, uuid.Nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.products.ListProducts(ctx, first[0].ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 || first[0].ID != products[0].ID || (len(products) > 1 && (len(second) != 1 || second[0].ID != products[1].ID)) {
+		t.Fatalf("expected pages of one product to follow each other, got %+v and %+v", first, second)
+	}
+	products, err = b.products.GetProducts(ctx, []uuid.UUID{product.ID, uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].ID != product.ID {
+		t.Fatalf("expected only product %s to be selected, got %+v", product.ID, products)
+	}
+
+	_, err = b.products.GetProduct(ctx, uuid.New())
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeProductNotFound)
+}
+
+func testConformanceAvailabilities(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	product, availabilities := seedProduct(t, b, 3, 0, 1)
+
+	inserted, err := b.availability.InsertAvailabilities(ctx, []Availability{
+		{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(conformanceToday.AddDate(0, 0, 1))},
+		{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(conformanceToday.AddDate(0, 0, 2))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 1 {
+		t.Fatalf("expected existing day to be skipped, inserted %d", inserted)
+	}
+
+	day, err := b.availability.GetAvailability(ctx, product.ID, conformanceToday.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(day) != 1 || day[0].ID != availabilities[1].ID || day[0].Vacancies != 3 || day[0].Status != AvailabilityStatusAvailable || !day[0].Available {
+		t.Fatalf("expected availability %s with 3 vacancies, got %+v", availabilities[1].ID, day)
+	}
+	days, err := b.availability.GetAvailabilityTo(ctx, product.ID, conformanceToday, conformanceToday.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 availabilities in range, got %d", len(days))
+	}
+	byID, err := b.availability.GetAvailabilityByID(ctx, availabilities[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.ProductID != product.ID || !time.Time(byID.LocalDate).Equal(conformanceToday) {
+		t.Fatalf("expected availability of product %s today, got %+v", product.ID, byID)
+	}
+
+	_, err = b.availability.GetAvailabilityByID(ctx, uuid.New())
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeAvailabilityNotFound)
+}
+
+func testConformanceCoverage(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	covered, _ := seedProduct(t, b, 1, 0, 1, 2)
+	empty, _ := seedProduct(t, b, 1)
+
+	coverage, err := b.availability.GetAvailabilityCoverage(ctx, []uuid.UUID{covered.ID, empty.ID, uuid.New()}, conformanceToday, conformanceToday.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage) != 2 {
+		t.Fatalf("expected coverage of 2 existing products, got %d", len(coverage))
+	}
+	c := coverage[covered.ID]
+	if c.LatestDate == nil || !c.LatestDate.Equal(conformanceToday.AddDate(0, 0, 2)) || len(c.Dates) != 2 || !c.Dates[0].Equal(conformanceToday) {
+		t.Fatalf("expected latest date in 2 days and 2 dates in range, got %+v", c)
+	}
+	if c := coverage[empty.ID]; c.LatestDate != nil || len(c.Dates) != 0 {
+		t.Fatalf("expected product without availability to have empty coverage, got %+v", c)
+	}
+
+	product := Product{ID: uuid.New(), Name: "short", Capacity: 1, HorizonDays: 2}
+	b.addProduct(t, product, Pricing{Price: 1000, Currency: "EUR"})
+	notCovered, err := b.availability.GetProductsNotCovered(ctx, conformanceToday, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(notCovered, product.ID) {
+		t.Fatalf("expected product %s without availability not to be covered", product.ID)
+	}
+	_, err = b.availability.InsertAvailabilities(ctx, []Availability{{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(conformanceToday.AddDate(0, 0, 1))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notCovered, err = b.availability.GetProductsNotCovered(ctx, conformanceToday, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(notCovered, product.ID) {
+		t.Fatalf("expected product %s to be covered within slack", product.ID)
+	}
+}
+
+func testConformanceBookings(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	product, availabilities := seedProduct(t, b, 3, 0)
+	availability := availabilities[0]
+
+	booking, err := b.bookings.CreateBooking(ctx, availability, 2, BookingDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != BookingStatusReserved || booking.ProductID != product.ID || booking.AvailabilityID != availability.ID || len(booking.Units) != 2 {
+		t.Fatalf("expected reserved booking with 2 units, got %+v", booking)
+	}
+	for _, unit := range booking.Units {
+		if unit.Ticket != nil {
+			t.Fatalf("expected reserved booking not to have tickets, got %+v", booking)
+		}
+	}
+	_, err = b.bookings.CreateBooking(ctx, availability, 2, BookingDetails{})
+	expectDomainError(t, err, pkg.ErrConflict, ProblemCodeInsufficientVacancies)
+	if _, err := b.bookings.CreateBooking(ctx, availability, 1, BookingDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.bookings.CreateBooking(ctx, availability, 1, BookingDetails{})
+	expectDomainError(t, err, pkg.ErrConflict, ProblemCodeAvailabilitySoldOut)
+	current, err := b.availability.GetAvailabilityByID(ctx, availability.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Vacancies != 0 || current.Status != AvailabilityStatusSoldOut || current.Available {
+		t.Fatalf("expected sold out availability, got %+v", current)
+	}
+	_, err = b.bookings.CreateBooking(ctx, Availability{ID: uuid.New(), Vacancies: 1}, 1, BookingDetails{})
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeAvailabilityNotFound)
+
+	got, err := b.bookings.GetBooking(ctx, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != booking.ID || len(got.Units) != 2 {
+		t.Fatalf("expected booking %+v, got %+v", booking, got)
+	}
+	confirmed, err := b.bookings.ConfirmBooking(ctx, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Status != BookingStatusConfirmed {
+		t.Fatalf("expected confirmed booking, got %+v", confirmed)
+	}
+	for _, unit := range confirmed.Units {
+		if unit.Ticket == nil || *unit.Ticket == "" {
+			t.Fatalf("expected confirmed booking to have tickets, got %+v", confirmed)
+		}
+	}
+	_, err = b.bookings.ConfirmBooking(ctx, booking.ID)
+	expectDomainError(t, err, pkg.ErrConflict, ProblemCodeBookingAlreadyConfirmed)
+	_, err = b.bookings.GetBooking(ctx, uuid.New())
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeBookingNotFound)
+	_, err = b.bookings.ConfirmBooking(ctx, uuid.New())
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeBookingNotFound)
+}
+
+func testConformanceBookingDetails(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	_, availabilities := seedProduct(t, b, 3, 0)
+	details := BookingDetails{
+		Contact:           Contact{FullName: "Jan Novák", EmailAddress: "jan@example.com", PhoneNumber: "+420123456789"},
+		ResellerReference: "R-1",
+	}
+
+	booking, err := b.bookings.CreateBooking(ctx, availabilities[0], 1, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.BookingDetails != details {
+		t.Fatalf("expected booking to have details %+v, got %+v", details, booking.BookingDetails)
+	}
+	// concurrent updates of different fields are merged into the latest details
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, update := range []func(details BookingDetails) BookingDetails{
+		func(details BookingDetails) BookingDetails {
+			details.Contact.PhoneNumber = ""
+			return details
+		},
+		func(details BookingDetails) BookingDetails {
+			details.Notes = "late arrival"
+			return details
+		},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.bookings.UpdateBookingDetails(ctx, booking.ID, func(details BookingDetails) (BookingDetails, error) {
+				return update(details), nil
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	details.Contact.PhoneNumber = ""
+	details.Notes = "late arrival"
+	got, err := b.bookings.GetBooking(ctx, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BookingDetails != details {
+		t.Fatalf("expected booking to have updated details %+v, got %+v", details, got.BookingDetails)
+	}
+
+	cleared, err := b.bookings.UpdateBookingDetails(ctx, booking.ID, func(BookingDetails) (BookingDetails, error) {
+		return BookingDetails{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared.BookingDetails != (BookingDetails{}) {
+		t.Fatalf("expected details to be cleared, got %+v", cleared.BookingDetails)
+	}
+	rejected := errors.New("rejected")
+	if _, err := b.bookings.UpdateBookingDetails(ctx, booking.ID, func(BookingDetails) (BookingDetails, error) {
+		return details, rejected
+	}); !errors.Is(err, rejected) {
+		t.Fatalf("expected error of update to be returned, got %v", err)
+	}
+
+	_, err = b.bookings.UpdateBookingDetails(ctx, uuid.New(), func(details BookingDetails) (BookingDetails, error) {
+		return details, nil
+	})
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodeBookingNotFound)
+}
+
+func testConformanceDataSubject(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	_, availabilities := seedProduct(t, b, 5, 0)
+	emailAddress := uuid.NewString() + "@example.com"
+	details := BookingDetails{
+		Contact:           Contact{FullName: "Jan Novák", EmailAddress: emailAddress, PhoneNumber: "+420123456789", Country: "CZ"},
+		Notes:             "allergic to nuts",
+		ResellerReference: "R-1",
+	}
+	booking, err := b.bookings.CreateBooking(ctx, availabilities[0], 2, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := b.bookings.CreateBooking(ctx, availabilities[0], 1, BookingDetails{Contact: Contact{EmailAddress: "other." + emailAddress}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(booking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// booking creation retried with the key would replay the stored response
+	storeIdempotentResponse(t, b, booking.ID.String(), pkg.IdempotentResponse{Status: 200, ContentType: "application/json", Body: body})
+	storeIdempotentResponse(t, b, "plain-"+booking.ID.String(), pkg.IdempotentResponse{Status: 200, ContentType: "text/plain", Body: []byte(emailAddress)})
+	audit := PrivacyAudit{Subject: privacySubject(testPrivacySubjectKey, emailAddress), Source: PrivacySourceCLI, CreatedAt: conformanceToday}
+
+	audit.Action = PrivacyActionExport
+	bookings, err := b.privacy.ExportBookings(ctx, strings.ToUpper(emailAddress), audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 1 || bookings[0].ID != booking.ID || len(bookings[0].Units) != 2 || bookings[0].BookingDetails != details {
+		t.Fatalf("expected booking with its tickets to be exported regardless of email case, got %+v", bookings)
+	}
+
+	audit.Action = PrivacyActionErasure
+	audit.CreatedAt = conformanceToday.Add(time.Hour)
+	bookingIDs, err := b.privacy.EraseContacts(ctx, emailAddress, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(bookingIDs, []uuid.UUID{booking.ID}) {
+		t.Fatalf("expected only booking %s to be erased, got %v", booking.ID, bookingIDs)
+	}
+	erased, err := b.bookings.GetBooking(ctx, booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erased.BookingDetails != (BookingDetails{ResellerReference: "R-1"}) || len(erased.Units) != 2 {
+		t.Fatalf("expected contact and notes to be erased and booking kept, got %+v", erased)
+	}
+	replayed, err := b.idempotency.ReserveIdempotencyKey(ctx, booking.ID.String(), "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayedBooking Booking
+	if err := json.Unmarshal(replayed.Body, &replayedBooking); err != nil {
+		t.Fatal(err)
+	}
+	if replayedBooking.ID != booking.ID || replayedBooking.BookingDetails != (BookingDetails{ResellerReference: "R-1"}) || replayed.Status != 200 {
+		t.Fatalf("expected contact and notes to be scrubbed from stored response, got %+v", replayedBooking)
+	}
+	if plain, err := b.idempotency.ReserveIdempotencyKey(ctx, "plain-"+booking.ID.String(), "fingerprint"); err != nil || string(plain.Body) != emailAddress {
+		t.Fatalf("expected responses that are not bookings to be kept, got %+v %v", plain, err)
+	}
+	kept, err := b.bookings.GetBooking(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Contact.EmailAddress != "other."+emailAddress {
+		t.Fatalf("expected contact of other booking to be kept, got %+v", kept.Contact)
+	}
+
+	audits, err := b.privacy.ListPrivacyAudits(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) != 2 || audits[0].Action != PrivacyActionErasure || audits[1].Action != PrivacyActionExport || audits[0].Bookings != 1 {
+		t.Fatalf("expected erasure and export to be audited, got %+v", audits)
+	}
+	if audits[0].ID == uuid.Nil || audits[0].Subject != audit.Subject {
+		t.Fatalf("expected audit to identify subject by hash, got %+v", audits[0])
+	}
+}
+
+// storeIdempotentResponse stores response of completed request with key
+func storeIdempotentResponse(t *testing.T, b conformanceBackend, key string, response pkg.IdempotentResponse) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := b.idempotency.ReserveIdempotencyKey(ctx, key, "fingerprint"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.idempotency.CompleteIdempotencyKey(ctx, key, response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testConformanceIdempotency(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	key := uuid.NewString()
+
+	stored, err := b.idempotency.ReserveIdempotencyKey(ctx, key, "request")
+	if err != nil || stored != nil {
+		t.Fatalf("expected new key to be reserved, got %+v %v", stored, err)
+	}
+	_, err = b.idempotency.ReserveIdempotencyKey(ctx, key, "request")
+	expectDomainError(t, err, pkg.ErrConflict, pkg.ProblemCodeIdempotencyKeyInUse)
+	// request that crashed holds the key only until its lease ends, then its retry reclaims it
+	leaseStart := b.clock.Now()
+	b.clock.Set(leaseStart.Add(idempotencyKeyLease + time.Second))
+	_, err = b.idempotency.ReserveIdempotencyKey(ctx, key, "other request")
+	expectDomainError(t, err, pkg.ErrValidation, pkg.ProblemCodeIdempotencyKeyReused)
+	stored, err = b.idempotency.ReserveIdempotencyKey(ctx, key, "request")
+	b.clock.Set(leaseStart)
+	if err != nil || stored != nil {
+		t.Fatalf("expected key with ended lease to be reclaimed, got %+v %v", stored, err)
+	}
+	if err := b.idempotency.ReleaseIdempotencyKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	storeIdempotentResponse(t, b, key, pkg.IdempotentResponse{Status: 201, ContentType: "application/json", Body: []byte(`{"id":"1"}`)})
+
+	stored, err = b.idempotency.ReserveIdempotencyKey(ctx, key, "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.Status != 201 || string(stored.Body) != `{"id":"1"}` {
+		t.Fatalf("expected completed response to be replayed, got %+v", stored)
+	}
+	_, err = b.idempotency.ReserveIdempotencyKey(ctx, key, "other request")
+	expectDomainError(t, err, pkg.ErrValidation, pkg.ProblemCodeIdempotencyKeyReused)
+	if err := b.idempotency.ReleaseIdempotencyKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := b.idempotency.ReserveIdempotencyKey(ctx, key, "fingerprint"); err != nil || stored == nil {
+		t.Fatalf("expected completed key not to be released, got %+v %v", stored, err)
+	}
+
+	// keys of other cases expire as well, the clock is moved back so that they do not affect later cases
+	now := b.clock.Now()
+	b.clock.Set(now.Add(idempotencyKeyTTL + time.Minute))
+	t.Cleanup(func() { b.clock.Set(now) })
+	deleted, err := b.idempotency.PurgeExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted < 1 {
+		t.Fatalf("expected expired key to be purged, got %d", deleted)
+	}
+	if stored, err := b.idempotency.ReserveIdempotencyKey(ctx, key, "other request"); err != nil || stored != nil {
+		t.Fatalf("expected purged key to be reserved for a new request, got %+v %v", stored, err)
+	}
+}
+
+func testConformanceOverbooking(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	_, availabilities := seedProduct(t, b, 5, 0)
+	// every request sees all vacancies, processor must count them again
+	stale, err := b.availability.GetAvailabilityByID(ctx, availabilities[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	created := 0
+	wg := sync.WaitGroup{}
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.bookings.CreateBooking(ctx, stale, 1, BookingDetails{})
+			if err != nil && !errors.Is(err, pkg.ErrConflict) {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 5 {
+		t.Fatalf("expected exactly 5 bookings to be created, got %d", created)
+	}
+	current, err := b.availability.GetAvailabilityByID(ctx, stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Vacancies != 0 {
+		t.Fatalf("expected no vacancies left, got %d", current.Vacancies)
+	}
+}
+
+func testConformancePricing(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	product, availabilities := seedProduct(t, b, 3, 0)
+
+	products, err := b.pricing.GetPricedProducts(ctx, []Product{product}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if products[0].Price != 1000 || products[0].Currency != "EUR" {
+		t.Fatalf("expected price 1000 EUR, got %+v", products[0].Pricing)
+	}
+	pricedAvailabilities, err := b.pricing.GetPricedAvailabilities(ctx, availabilities, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pricedAvailabilities[0].Price != 1000 {
+		t.Fatalf("expected availability price 1000, got %d", pricedAvailabilities[0].Price)
+	}
+	booking, err := b.bookings.CreateBooking(ctx, availabilities[0], 2, BookingDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookings, err := b.pricing.GetPricedBookings(ctx, []Booking{booking}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bookings[0].Price != 2000 || bookings[0].Units[0].Price != 1000 {
+		t.Fatalf("expected booking price to be sum of its units, got %+v", bookings[0])
+	}
+
+	_, err = b.pricing.GetPricedProducts(ctx, []Product{product}, "USD")
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodePricingNotFound)
+
+	other, _ := seedProduct(t, b, 3)
+	version, err := b.pricing.GetPricingVersion(ctx, product.ID, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherVersion, err := b.pricing.GetPricingVersion(ctx, other.ID, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version == 0 || otherVersion == version {
+		t.Fatalf("expected every pricing to get its own version, got %d and %d", version, otherVersion)
+	}
+	if version, err := b.pricing.GetPricingVersion(ctx, product.ID, "USD"); err != nil || version != 0 {
+		t.Fatalf("expected missing pricing to have version 0, got %d %v", version, err)
+	}
+}
+
+func testConformanceCalendar(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	product, availabilities := seedProduct(t, b, 3, 0, 1)
+	if _, err := b.bookings.CreateBooking(ctx, availabilities[0], 2, BookingDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.bookings.CreateBooking(ctx, availabilities[1], 3, BookingDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := b.availability.GetAvailabilityCalendar(ctx, product.ID, conformanceToday, conformanceToday.AddDate(0, 0, 30), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].Vacancies != 1 || days[1].Vacancies != 0 || days[1].Status != AvailabilityStatusSoldOut || days[0].LowestPrice != nil {
+		t.Fatalf("expected 2 unpriced days with 1 and 0 vacancies, got %+v", days)
+	}
+	if !time.Time(days[0].LocalDate).Equal(conformanceToday) {
+		t.Fatalf("expected days to be ordered, got %+v", days)
+	}
+	days, err = b.availability.GetAvailabilityCalendar(ctx, product.ID, conformanceToday, conformanceToday, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].LowestPrice == nil || *days[0].LowestPrice != 1000 || days[0].Currency != "EUR" {
+		t.Fatalf("expected day priced 1000 EUR, got %+v", days)
+	}
+
+	_, err = b.availability.GetAvailabilityCalendar(ctx, product.ID, conformanceToday, conformanceToday, "USD")
+	expectDomainError(t, err, pkg.ErrNotFound, ProblemCodePricingNotFound)
+}
+
+func testConformanceProductsAvailabilities(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	first, _ := seedProduct(t, b, 5, 2, 0)
+	second, _ := seedProduct(t, b, 5, 1, 40)
+	other, _ := seedProduct(t, b, 5, 0)
+
+	availabilities, err := b.availability.GetProductsAvailabilityTo(ctx, []uuid.UUID{first.ID, second.ID}, conformanceToday, conformanceToday.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(availabilities) != 3 {
+		t.Fatalf("expected 3 availabilities of both products within range, got %+v", availabilities)
+	}
+	for i, availability := range availabilities {
+		if availability.ProductID == other.ID {
+			t.Fatalf("expected availabilities of other product not to be returned, got %+v", availability)
+		}
+		if i > 0 && time.Time(availability.LocalDate).Before(time.Time(availabilities[i-1].LocalDate)) {
+			t.Fatalf("expected availabilities to be ordered by date, got %+v", availabilities)
+		}
+	}
+}
+
+func testConformanceAvailabilityChanges(t *testing.T, b conformanceBackend) {
+	_, availabilities := seedProduct(t, b, 100, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed := make(chan uuid.UUID, 100)
+	go func() {
+		_ = b.availability.ListenAvailabilityChanges(ctx, func(availabilityID uuid.UUID) {
+			changed <- availabilityID
+		})
+	}()
+
+	// listening starts asynchronously, bookings are created until the first change is received
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case availabilityID := <-changed:
+			if availabilityID != availabilities[0].ID {
+				continue
+			}
+			return
+		case <-ticker.C:
+			if _, err := b.bookings.CreateBooking(ctx, availabilities[0], 1, BookingDetails{}); err != nil {
+				t.Fatal(err)
+			}
+		case <-ctx.Done():
+			t.Fatal("availability change was not received")
+		}
+	}
+}
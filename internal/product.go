@@ -2,11 +2,14 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
 )
 
 type Product struct {
@@ -14,11 +17,63 @@ type Product struct {
 	Name string    `json:"name"`
 	// Capacity represents max number of vacancies per 1 day (availability)
 	Capacity int `json:"capacity"`
+	// HorizonDays is how many days ahead of today the product can be booked, availability is generated that far
+	HorizonDays int `json:"horizonDays"`
+	// LeadTimeMinutes is how long before the start of the booked day the bookings close, without lead time
+	// the day can be booked until its end
+	LeadTimeMinutes int `json:"leadTimeMinutes"`
+	// SalesCutoff is the last day product can be booked for, nil when sales do not end
+	SalesCutoff *JSONTime `json:"salesCutoff"`
+}
+
+// lastSaleDay returns the last day product can be booked for on today
+func (p Product) lastSaleDay(today time.Time) time.Time {
+	lastDay := today.AddDate(0, 0, p.HorizonDays)
+	if p.SalesCutoff != nil && time.Time(*p.SalesCutoff).Before(lastDay) {
+		return time.Time(*p.SalesCutoff)
+	}
+	return lastDay
+}
+
+// checkSalesWindow checks that day can be booked at now
+func (p Product) checkSalesWindow(day time.Time, now time.Time) error {
+	today := now.UTC().Truncate(24 * time.Hour)
+	dayEnd := day.AddDate(0, 0, 1)
+	leadTime := time.Duration(p.LeadTimeMinutes) * time.Minute
+	if !now.Before(dayEnd) {
+		return pkg.NewDomainError(pkg.ErrGone, ProblemCodeAvailabilityExpired, fmt.Sprintf("day %s has already passed", day.Format(timeFormat)))
+	}
+	// lead time is measured from the start of the day, the day has no explicit start time
+	if leadTime > 0 && now.Add(leadTime).After(day) {
+		return newOutsideSalesWindowError(fmt.Sprintf("Bookings close %d minutes before the start of the booked day", p.LeadTimeMinutes))
+	}
+	if p.SalesCutoff != nil && day.After(time.Time(*p.SalesCutoff)) {
+		return newOutsideSalesWindowError(fmt.Sprintf("Product can be booked only until %s", time.Time(*p.SalesCutoff).Format(timeFormat)))
+	}
+	if day.After(today.AddDate(0, 0, p.HorizonDays)) {
+		return newOutsideSalesWindowError(fmt.Sprintf("Product can be booked at most %d days ahead", p.HorizonDays))
+	}
+	return nil
+}
+
+func newOutsideSalesWindowError(reason string) error {
+	return pkg.NewDomainError(
+		pkg.ErrValidation,
+		ProblemCodeOutsideSalesWindow,
+		"availability is outside of sales window of the product",
+		pkg.InvalidParam{
+			Name:   "availabilityId",
+			Reason: reason,
+		},
+	)
 }
 
 type ProductProcessor interface {
 	GetProduct(ctx context.Context, id uuid.UUID) (Product, error)
-	ListProducts(ctx context.Context) ([]Product, error)
+	// ListProducts returns at most limit products ordered by ID following after, uuid.Nil starts with the first one
+	ListProducts(ctx context.Context, after uuid.UUID, limit int) ([]Product, error)
+	// GetProducts returns products with ids, unknown ids are skipped
+	GetProducts(ctx context.Context, ids []uuid.UUID) ([]Product, error)
 }
 
 var _ ProductProcessor = &ProductRepository{}
@@ -33,14 +88,19 @@ type ProductRepository struct {
 	db *pgxpool.Pool
 }
 
+const baseProductQuery = "SELECT id, name, capacity, horizon_days, lead_time_minutes, sales_cutoff FROM ventrata.products"
+
 func (p *ProductRepository) GetProduct(ctx context.Context, id uuid.UUID) (Product, error) {
-	rows, err := p.db.Query(ctx, "SELECT id, name, capacity FROM ventrata.products WHERE id = $1", id)
+	rows, err := p.db.Query(ctx, baseProductQuery+" WHERE id = $1", id)
 	if err != nil {
 		return Product{}, fmt.Errorf("querying product by id failed: %w", err)
 	}
 	defer rows.Close()
 
-	product, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Product])
+	product, err := pgx.CollectOneRow(rows, scanProduct)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Product{}, pkg.NewDomainError(pkg.ErrNotFound, ProblemCodeProductNotFound, fmt.Sprintf("product %s not found", id))
+	}
 	if err != nil {
 		return Product{}, fmt.Errorf("scanning product row failed: %w", err)
 	}
@@ -48,17 +108,46 @@ func (p *ProductRepository) GetProduct(ctx context.Context, id uuid.UUID) (Produ
 	return product, nil
 }
 
-func (p *ProductRepository) ListProducts(ctx context.Context) ([]Product, error) {
-	rows, err := p.db.Query(ctx, "SELECT id, name, capacity FROM ventrata.products")
+func (p *ProductRepository) ListProducts(ctx context.Context, after uuid.UUID, limit int) ([]Product, error) {
+	rows, err := p.db.Query(ctx, baseProductQuery+" WHERE id > $1 ORDER BY id LIMIT $2", after, limit)
 	if err != nil {
 		return nil, fmt.Errorf("querying products failed: %w", err)
 	}
 	defer rows.Close()
 
-	product, err := pgx.CollectRows(rows, pgx.RowToStructByName[Product])
+	product, err := pgx.CollectRows(rows, scanProduct)
 	if err != nil {
 		return nil, fmt.Errorf("scanning product rows failed: %w", err)
 	}
 
 	return product, nil
 }
+
+func (p *ProductRepository) GetProducts(ctx context.Context, ids []uuid.UUID) ([]Product, error) {
+	rows, err := p.db.Query(ctx, baseProductQuery+" WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, fmt.Errorf("querying products by ids failed: %w", err)
+	}
+	defer rows.Close()
+
+	products, err := pgx.CollectRows(rows, scanProduct)
+	if err != nil {
+		return nil, fmt.Errorf("scanning product rows failed: %w", err)
+	}
+
+	return products, nil
+}
+
+func scanProduct(row pgx.CollectableRow) (Product, error) {
+	var product Product
+	var salesCutoff *time.Time
+	err := row.Scan(&product.ID, &product.Name, &product.Capacity, &product.HorizonDays, &product.LeadTimeMinutes, &salesCutoff)
+	if err != nil {
+		return Product{}, err
+	}
+	if salesCutoff != nil {
+		cutoff := JSONTime(*salesCutoff)
+		product.SalesCutoff = &cutoff
+	}
+	return product, nil
+}
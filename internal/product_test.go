@@ -2,9 +2,13 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prathoss/hw/pkg"
 )
 
 func TestProductRepository_GetProduct(t *testing.T) {
@@ -19,7 +23,7 @@ func TestProductRepository_GetProduct(t *testing.T) {
 		t.Fatal(err)
 	}
 	productRepository := NewProductRepository(pool)
-	products, err := productRepository.ListProducts(ctx)
+	products, err := productRepository.ListProducts(ctx, uuid.Nil, productPageSize)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -27,3 +31,67 @@ func TestProductRepository_GetProduct(t *testing.T) {
 		t.Fatalf("expected number of products returned to be 0, but got %d", len(products))
 	}
 }
+
+func TestProductRepository_GetProduct_NotFound(t *testing.T) {
+	pgConn, cleanup, err := setupPgAndMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, pgConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productRepository := NewProductRepository(pool)
+	_, err = productRepository.GetProduct(ctx, uuid.New())
+	if !errors.Is(err, pkg.ErrNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestProduct_checkSalesWindow(t *testing.T) {
+	now := time.Date(2024, 6, 15, 20, 0, 0, 0, time.UTC)
+	today := now.Truncate(24 * time.Hour)
+	cutoff := JSONTime(today.AddDate(0, 0, 10))
+	product := Product{
+		HorizonDays:     30,
+		LeadTimeMinutes: 120,
+		SalesCutoff:     &cutoff,
+	}
+
+	tests := []struct {
+		name    string
+		product Product
+		day     time.Time
+		now     time.Time
+		code    string
+	}{
+		{name: "tomorrow before lead time", product: product, day: today.AddDate(0, 0, 1), now: now},
+		{name: "cutoff day", product: product, day: today.AddDate(0, 0, 10), now: now},
+		{name: "yesterday", product: product, day: today.AddDate(0, 0, -1), now: now, code: ProblemCodeAvailabilityExpired},
+		{name: "tomorrow within lead time", product: product, day: today.AddDate(0, 0, 1), now: now.Add(3 * time.Hour), code: ProblemCodeOutsideSalesWindow},
+		{name: "same day within lead time", product: product, day: today, now: today.Add(time.Hour), code: ProblemCodeOutsideSalesWindow},
+		{name: "same day without lead time", product: Product{HorizonDays: 30}, day: today, now: now},
+		{name: "after cutoff", product: product, day: today.AddDate(0, 0, 11), now: now, code: ProblemCodeOutsideSalesWindow},
+		{name: "beyond horizon", product: Product{HorizonDays: 30}, day: today.AddDate(0, 0, 31), now: now, code: ProblemCodeOutsideSalesWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.product.checkSalesWindow(tt.day, tt.now)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("expected day to be bookable, got %v", err)
+				}
+				return
+			}
+			var domainErr *pkg.DomainError
+			if !errors.As(err, &domainErr) {
+				t.Fatalf("expected domain error with code %s, got %v", tt.code, err)
+			}
+			if domainErr.Code != tt.code {
+				t.Fatalf("expected code %s, got %s", tt.code, domainErr.Code)
+			}
+		})
+	}
+}
@@ -5,15 +5,17 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prathoss/hw/pkg"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron/v3"
 )
 
@@ -22,38 +24,170 @@ const CapabilityPricing = "pricing"
 //go:embed openapi.yaml
 var openApi []byte
 
-func NewServer(config Config) (*Server, error) {
-	pool, err := pgxpool.New(context.Background(), config.DatabaseDSN)
+// NewServer creates server configured by options, without WithProcessors it is backed by database from config
+func NewServer(config Config, options ...ServerOption) (*Server, error) {
+	s := &Server{
+		config:             config,
+		clock:              systemClock{},
+		newID:              uuid.New,
+		logger:             slog.Default(),
+		scheduler:          cron.New(),
+		createBookingMu:    sync.Mutex{},
+		availabilityBroker: newAvailabilityBroker(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.injectedProcessors {
+		if missing := s.missingProcessors(); len(missing) > 0 {
+			return nil, fmt.Errorf("processors %s are not set", strings.Join(missing, ", "))
+		}
+		s.injectDependencies()
+	} else {
+		pool, err := newPool(config.Database)
+		if err != nil {
+			return nil, err
+		}
+		bookingRepository := NewBookingRepository(pool)
+		bookingRepository.newID = s.newID
+		jobRunRepository := NewJobRunRepository(pool)
+		jobRunRepository.newID = s.newID
+		idempotencyRepository := NewIdempotencyRepository(pool)
+		idempotencyRepository.clock = s.clock
+		WithProcessors(Processors{
+			Product:      NewProductRepository(pool),
+			Pricing:      NewPricingRepository(pool),
+			Availability: NewAvailabilityRepository(pool),
+			Booking:      bookingRepository,
+			Health:       NewHealthRepository(pool),
+			JobRun:       jobRunRepository,
+			Privacy:      bookingRepository,
+			Idempotency:  idempotencyRepository,
+			Locker:       NewAdvisoryLocker(pool),
+		})(s)
+		s.db = pool
+	}
+	metricsRegistry, err := newMetricsRegistry(s.db)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.metricsRegistry = metricsRegistry
+	return s, nil
+}
+
+// NewServerWithProcessors creates server backed by processors instead of database, e.g. by MemoryStore
+func NewServerWithProcessors(config Config, processors Processors, options ...ServerOption) (*Server, error) {
+	return NewServer(config, append([]ServerOption{WithProcessors(processors)}, options...)...)
+}
+
+// missingProcessors returns names of required processors that were not injected, only Idempotency is optional
+func (s *Server) missingProcessors() []string {
+	required := []struct {
+		name  string
+		isSet bool
+	}{
+		{name: "Product", isSet: s.productProcessor != nil},
+		{name: "Pricing", isSet: s.pricingProcessor != nil},
+		{name: "Availability", isSet: s.availabilityProcessor != nil},
+		{name: "Booking", isSet: s.bookingProcessor != nil},
+		{name: "Health", isSet: s.healthProcessor != nil},
+		{name: "JobRun", isSet: s.jobRunProcessor != nil},
+		{name: "Privacy", isSet: s.privacyProcessor != nil},
+		{name: "Locker", isSet: s.locker != nil},
+	}
+	missing := make([]string, 0)
+	for _, processor := range required {
+		if !processor.isSet {
+			missing = append(missing, processor.name)
+		}
+	}
+	return missing
+}
+
+// dependentProcessor is processor created outside of server that generates IDs or reads time, e.g. MemoryStore
+type dependentProcessor interface {
+	setDependencies(newID IDGenerator, clock Clock)
+}
+
+// injectDependencies passes ID generator and clock of the server to injected processors, so that WithIDGenerator
+// and WithClock apply to them the same way they apply to repositories
+func (s *Server) injectDependencies() {
+	processors := []any{
+		s.productProcessor,
+		s.pricingProcessor,
+		s.availabilityProcessor,
+		s.bookingProcessor,
+		s.healthProcessor,
+		s.jobRunProcessor,
+		s.privacyProcessor,
+		s.idempotencyProcessor,
+		s.locker,
+	}
+	for _, processor := range processors {
+		if dependent, ok := processor.(dependentProcessor); ok {
+			dependent.setDependencies(s.newID, s.clock)
+		}
+	}
+}
+
+func newPool(config DatabaseConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DSN)
 	if err != nil {
 		return nil, err
 	}
-	return &Server{
-		db:                    pool,
-		config:                config,
-		productProcessor:      NewProductRepository(pool),
-		pricingProcessor:      NewPricingRepository(pool),
-		availabilityProcessor: NewAvailabilityRepository(pool),
-		bookingProcessor:      NewBookingRepository(pool),
-		createBookingMu:       sync.Mutex{},
-	}, nil
+	poolConfig.MaxConns = int32(config.MaxConns)
+	poolConfig.MinConns = int32(config.MinConns)
+	poolConfig.ConnConfig.Tracer = &pkg.PgxTracer{}
+	return pgxpool.NewWithConfig(context.Background(), poolConfig)
+}
+
+// Processors are backends of the server
+type Processors struct {
+	Product      ProductProcessor
+	Pricing      PricingProcessor
+	Availability AvailabilityProcessor
+	Booking      BookingProcessor
+	Health       HealthProcessor
+	JobRun       JobRunProcessor
+	Privacy      PrivacyProcessor
+	// Idempotency is optional, Idempotency-Key is ignored without it
+	Idempotency IdempotencyProcessor
+	Locker      Locker
+}
+
+// Close releases database connections, it is meant for servers that were not run (e.g. used by CLI commands)
+func (s *Server) Close() {
+	if s.db != nil {
+		s.db.Close()
+	}
 }
 
 type Server struct {
+	// db is nil when server is created from processors
 	db                    *pgxpool.Pool
+	metricsRegistry       *prometheus.Registry
 	config                Config
 	productProcessor      ProductProcessor
 	pricingProcessor      PricingProcessor
 	availabilityProcessor AvailabilityProcessor
 	bookingProcessor      BookingProcessor
-	createBookingMu       sync.Mutex
-}
-
-func (s *Server) handleHealth(_ http.ResponseWriter, r *http.Request) (any, error) {
-	err := s.db.Ping(r.Context())
-	if err != nil {
-		return nil, pkg.NewServiceUnavailableError(err)
-	}
-	return nil, nil
+	healthProcessor       HealthProcessor
+	jobRunProcessor       JobRunProcessor
+	privacyProcessor      PrivacyProcessor
+	idempotencyProcessor  IdempotencyProcessor
+	locker                Locker
+	clock                 Clock
+	newID                 IDGenerator
+	logger                *slog.Logger
+	// injectedProcessors is set by WithProcessors, server is backed by database otherwise
+	injectedProcessors bool
+	// scheduler is nil when periodic jobs are disabled
+	scheduler Scheduler
+	// schedulerRunning is set by Run, readiness checks only scheduler that was started
+	schedulerRunning   atomic.Bool
+	createBookingMu    sync.Mutex
+	availabilityBroker *availabilityBroker
 }
 
 func (s *Server) listProducts(_ http.ResponseWriter, r *http.Request) (any, error) {
@@ -67,13 +201,13 @@ func (s *Server) listProducts(_ http.ResponseWriter, r *http.Request) (any, erro
 		return nil, pkg.NewBadRequestError(invalidParams...)
 	}
 
-	products, err := s.productProcessor.ListProducts(r.Context())
+	products, err := s.listAllProducts(r.Context())
 	if err != nil {
 		return nil, err
 	}
 
 	if capability == CapabilityPricing {
-		return s.pricingProcessor.GetPricedProducts(r.Context(), products, getCurrency())
+		return s.pricingProcessor.GetPricedProducts(r.Context(), products, s.config.DefaultCurrency)
 	}
 	return products, nil
 }
@@ -99,7 +233,7 @@ func (s *Server) getProductDetail(_ http.ResponseWriter, r *http.Request) (any,
 	}
 
 	if capability == CapabilityPricing {
-		pricedProducts, err := s.pricingProcessor.GetPricedProducts(r.Context(), []Product{product}, getCurrency())
+		pricedProducts, err := s.pricingProcessor.GetPricedProducts(r.Context(), []Product{product}, s.config.DefaultCurrency)
 		if err != nil {
 			return nil, err
 		}
@@ -111,60 +245,139 @@ func (s *Server) getProductDetail(_ http.ResponseWriter, r *http.Request) (any,
 func (s *Server) listAvailability(_ http.ResponseWriter, r *http.Request) (any, error) {
 	capability := getCapabilityHeader(r)
 	invalidParams := validateCapability(capability)
+
+	request, from, to, validationErrors := decodeAvailabilityRequest(r.Body)
+	invalidParams = append(invalidParams, validationErrors...)
+
 	if len(invalidParams) > 0 {
 		return nil, pkg.NewBadRequestError(invalidParams...)
 	}
+	if request.grouped() {
+		return s.listProductsAvailability(r.Context(), request.ProductIds, from, to, capability)
+	}
 
-	body, err := io.ReadAll(r.Body)
+	product, err := s.productProcessor.GetProduct(r.Context(), request.ProductId)
 	if err != nil {
 		return nil, err
 	}
-	var rawMessage map[string]any
-	if err := json.Unmarshal(body, &rawMessage); err != nil {
-		return nil, pkg.NewBadRequestError(pkg.InvalidParam{
-			Name:   "Body",
-			Reason: err.Error(),
-		})
+	availabilities, err := s.availabilityProcessor.GetAvailabilityTo(r.Context(), product.ID, from, to)
+	if err != nil {
+		return nil, err
 	}
-	_, isRange := rawMessage["localDateStart"]
 
-	var availabilities []Availability
-	if isRange {
-		var request AvailabilityRangeRequest
-		if err := json.Unmarshal(body, &request); err != nil {
-			return nil, pkg.NewBadRequestError(pkg.InvalidParam{
-				Name:   "Body",
-				Reason: err.Error(),
-			})
+	if capability == CapabilityPricing {
+		return s.pricingProcessor.GetPricedAvailabilities(r.Context(), availabilities, s.config.DefaultCurrency)
+	}
+
+	return availabilities, nil
+}
+
+// listProductsAvailability returns availabilities grouped by product in one query, all products when productIDs
+// is empty
+func (s *Server) listProductsAvailability(ctx context.Context, productIDs []uuid.UUID, from time.Time, to time.Time, capability string) (any, error) {
+	products, err := s.selectProducts(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(productIDs) == 0 {
+		if invalidParams := validateProductDays("allProducts", len(products), from, to); len(invalidParams) > 0 {
+			return nil, pkg.NewBadRequestError(invalidParams...)
 		}
-		availabilities, err = s.availabilityProcessor.GetAvailabilityTo(r.Context(), request.ProductId, time.Time(request.LocalDateStart).UTC(), time.Time(request.LocalDateEnd).UTC())
+	}
+	selectedIDs := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		selectedIDs = append(selectedIDs, product.ID)
+	}
+	availabilities, err := s.availabilityProcessor.GetProductsAvailabilityTo(ctx, selectedIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if capability == CapabilityPricing {
+		pricedAvailabilities, err := s.pricingProcessor.GetPricedAvailabilities(ctx, availabilities, s.config.DefaultCurrency)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		var request AvailabilityDayRequest
-		if err := json.Unmarshal(body, &request); err != nil {
-			return nil, pkg.NewBadRequestError(pkg.InvalidParam{
-				Name:   "Body",
-				Reason: err.Error(),
-			})
-		}
-		availabilities, err = s.availabilityProcessor.GetAvailability(r.Context(), request.ProductId, time.Time(request.LocalDate).UTC())
+		return groupAvailabilities(products, pricedAvailabilities, func(a PricedAvailability) uuid.UUID { return a.ProductID }), nil
+	}
+	return groupAvailabilities(products, availabilities, func(a Availability) uuid.UUID { return a.ProductID }), nil
+}
+
+// availabilityCacheControl lets clients and CDNs reuse availability shortly, afterwards it is revalidated by ETag
+const availabilityCacheControl = "public, max-age=10, must-revalidate"
+
+// checkAvailabilityETag validates cached availability of product by booked counters of its availabilities and,
+// with pricing capability, by version of its pricing, so that not modified availability is neither priced nor encoded
+func (s *Server) checkAvailabilityETag(w http.ResponseWriter, r *http.Request, productID uuid.UUID, capability string, availabilities []Availability) error {
+	validators := make([]string, 0, len(availabilities)+2)
+	validators = append(validators, capability)
+	if capability == CapabilityPricing {
+		version, err := s.pricingProcessor.GetPricingVersion(r.Context(), productID, s.config.DefaultCurrency)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		validators = append(validators, fmt.Sprintf("%s %d", s.config.DefaultCurrency, version))
+	}
+	for _, availability := range availabilities {
+		validators = append(validators, fmt.Sprintf(
+			"%s %s %s %d %t",
+			availability.ID,
+			time.Time(availability.LocalDate).Format(timeFormat),
+			availability.Status,
+			availability.Vacancies,
+			availability.Available,
+		))
 	}
+	return pkg.CheckETag(w, r, validators...)
+}
 
-	if capability == CapabilityPricing {
-		return s.pricingProcessor.GetPricedAvailabilities(r.Context(), availabilities, getCurrency())
+func (s *Server) getProductAvailability(w http.ResponseWriter, r *http.Request) (any, error) {
+	invalidParams := make([]pkg.InvalidParam, 0, 10)
+
+	capability := getCapabilityHeader(r)
+	validationErrors := validateCapability(capability)
+	invalidParams = append(invalidParams, validationErrors...)
+
+	id, validationErrors := validateID(r.PathValue("id"))
+	invalidParams = append(invalidParams, validationErrors...)
+
+	from, to, validationErrors := parseDateRangeQuery(r.URL.Query())
+	invalidParams = append(invalidParams, validationErrors...)
+
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
+	}
+	if to.IsZero() {
+		to = from
+	}
+
+	product, err := s.productProcessor.GetProduct(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	availabilities, err := s.availabilityProcessor.GetAvailabilityTo(r.Context(), product.ID, from, to)
+	if err != nil {
+		return nil, err
 	}
 
+	// cached representation depends on requested capability
+	w.Header().Set("Vary", "Capability")
+	if err := s.checkAvailabilityETag(w, r, product.ID, capability, availabilities); err != nil {
+		return nil, err
+	}
+	if capability == CapabilityPricing {
+		return s.pricingProcessor.GetPricedAvailabilities(r.Context(), availabilities, s.config.DefaultCurrency)
+	}
 	return availabilities, nil
 }
 
 func (s *Server) createBooking(_ http.ResponseWriter, r *http.Request) (any, error) {
 	invalidParams := make([]pkg.InvalidParam, 0, 10)
 
+	capability := getCapabilityHeader(r)
+	validationErrors := validateCapability(capability)
+	invalidParams = append(invalidParams, validationErrors...)
+
 	var bookingRequest BookingRequest
 	if err := json.NewDecoder(r.Body).Decode(&bookingRequest); err != nil {
 		return nil, pkg.NewBadRequestError(pkg.InvalidParam{
@@ -178,6 +391,7 @@ func (s *Server) createBooking(_ http.ResponseWriter, r *http.Request) (any, err
 			Reason: "Must be greater than zero",
 		})
 	}
+	invalidParams = append(invalidParams, validateBookingDetails(bookingRequest.BookingDetails)...)
 	if len(invalidParams) > 0 {
 		return nil, pkg.NewBadRequestError(invalidParams...)
 	}
@@ -194,16 +408,31 @@ func (s *Server) createBooking(_ http.ResponseWriter, r *http.Request) (any, err
 	}
 
 	if availability.ProductID != bookingRequest.ProductID {
-		invalidParams = append(invalidParams, pkg.InvalidParam{
-			Name:   "productID",
-			Reason: "product availability mismatch",
-		})
+		return nil, pkg.NewDomainError(
+			pkg.ErrValidation,
+			ProblemCodeProductAvailabilityMismatch,
+			fmt.Sprintf("availability %s does not belong to product %s", availability.ID, bookingRequest.ProductID),
+			pkg.InvalidParam{
+				Name:   "productId",
+				Reason: "product availability mismatch",
+			},
+		)
 	}
-	if len(invalidParams) > 0 {
-		return nil, pkg.NewBadRequestError(invalidParams...)
+
+	product, err := s.productProcessor.GetProduct(r.Context(), availability.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if err := product.checkSalesWindow(time.Time(availability.LocalDate).UTC(), s.clock.Now().UTC()); err != nil {
+		return nil, err
 	}
 
-	return s.bookingProcessor.CreateBooking(r.Context(), availability, bookingRequest.Units)
+	booking, err := s.bookingProcessor.CreateBooking(r.Context(), availability, bookingRequest.Units, bookingRequest.BookingDetails)
+	if err != nil {
+		return nil, err
+	}
+	bookingsCreatedTotal.Inc()
+	return s.withCapability(r.Context(), booking, capability)
 }
 
 func (s *Server) getBookingDetail(_ http.ResponseWriter, r *http.Request) (any, error) {
@@ -226,29 +455,43 @@ func (s *Server) getBookingDetail(_ http.ResponseWriter, r *http.Request) (any,
 		return nil, err
 	}
 
-	if capability == CapabilityPricing {
-		bookings, err := s.pricingProcessor.GetPricedBookings(r.Context(), []Booking{booking}, getCurrency())
-		if err != nil {
-			return nil, err
-		}
-		return bookings[0], nil
-	}
-
-	return booking, nil
+	return s.withCapability(r.Context(), booking, capability)
 }
 
 func (s *Server) confirmBooking(_ http.ResponseWriter, r *http.Request) (any, error) {
+	invalidParams := make([]pkg.InvalidParam, 0, 10)
+
+	capability := getCapabilityHeader(r)
+	validationErrors := validateCapability(capability)
+	invalidParams = append(invalidParams, validationErrors...)
+
 	idStr := r.PathValue("id")
 	id, validationErrors := validateID(idStr)
-	if len(validationErrors) > 0 {
-		return nil, pkg.NewBadRequestError(validationErrors...)
+	invalidParams = append(invalidParams, validationErrors...)
+
+	if len(invalidParams) > 0 {
+		return nil, pkg.NewBadRequestError(invalidParams...)
 	}
 
-	return s.bookingProcessor.ConfirmBooking(r.Context(), id)
+	booking, err := s.bookingProcessor.ConfirmBooking(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	bookingsConfirmedTotal.Inc()
+	unitsSoldTotal.WithLabelValues(booking.ProductID.String()).Add(float64(len(booking.Units)))
+	return s.withCapability(r.Context(), booking, capability)
 }
 
-func getCurrency() string {
-	return "EUR"
+// withCapability extends booking with properties of requested capability
+func (s *Server) withCapability(ctx context.Context, booking Booking, capability string) (any, error) {
+	if capability != CapabilityPricing {
+		return booking, nil
+	}
+	bookings, err := s.pricingProcessor.GetPricedBookings(ctx, []Booking{booking}, s.config.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return bookings[0], nil
 }
 
 func validateCapability(capability string) []pkg.InvalidParam {
@@ -289,89 +532,115 @@ func getCapabilityHeader(r *http.Request) string {
 	return capability
 }
 
-func (s *Server) Run() error {
-	mux := http.NewServeMux()
+// newMux registers all routes of the server
+func (s *Server) newMux() *routeMux {
+	mux := newRouteMux()
 
-	mux.Handle("GET /api/v1/health", pkg.HttpHandler(s.handleHealth))
+	mux.HandleFunc("GET /api/v1/health", s.handleReadiness)
+	mux.Handle("GET /api/v1/health/live", pkg.HttpHandler(s.handleLiveness))
+	mux.HandleFunc("GET /api/v1/health/ready", s.handleReadiness)
 
 	mux.Handle("GET /api/v1/products", pkg.HttpHandler(s.listProducts))
 	mux.Handle("GET /api/v1/products/{id}", pkg.HttpHandler(s.getProductDetail))
+	mux.Handle("GET /api/v1/products/{id}/availability", pkg.CacheHandler(availabilityCacheControl, pkg.HttpHandler(s.getProductAvailability)))
+	mux.Handle("GET /api/v1/products/{id}/calendar", pkg.CacheHandler(availabilityCacheControl, pkg.HttpHandler(s.getAvailabilityCalendar)))
 
 	mux.Handle("POST /api/v1/availability", pkg.HttpHandler(s.listAvailability))
+	mux.HandleFunc("GET /api/v1/availability/stream", s.streamAvailability)
 
-	mux.Handle("POST /api/v1/bookings", pkg.HttpHandler(s.createBooking))
+	mux.Handle("POST /api/v1/bookings", s.idempotent(pkg.HttpHandler(s.createBooking)))
 	mux.Handle("GET /api/v1/bookings/{id}", pkg.HttpHandler(s.getBookingDetail))
-	mux.Handle("POST /api/v1/bookings/{id}/confirm", pkg.HttpHandler(s.confirmBooking))
-
-	mux.HandleFunc("POST /dev/v1/availability", func(w http.ResponseWriter, r *http.Request) {
-		s.CreateAvailabilities()
-		w.WriteHeader(http.StatusCreated)
-	})
+	mux.Handle("PATCH /api/v1/bookings/{id}", pkg.HttpHandler(s.updateBooking))
+	mux.Handle("POST /api/v1/bookings/{id}/confirm", s.idempotent(pkg.HttpHandler(s.confirmBooking)))
+
+	mux.Handle("GET /metrics", pkg.MetricsHandler(s.metricsRegistry))
+
+	if s.config.AdminToken != "" {
+		mux.Handle("GET /admin/v1/log-level", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.getLogLevel)))
+		mux.Handle("PUT /admin/v1/log-level", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.setLogLevel)))
+		mux.Handle("GET /admin/v1/jobs/{job}/runs", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.listJobRuns)))
+		mux.Handle("POST /admin/v1/availability/generate", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.generateAvailabilitiesHandler)))
+		mux.Handle("POST /admin/v1/privacy/export", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.exportDataSubjectHandler)))
+		mux.Handle("POST /admin/v1/privacy/erase", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.eraseDataSubjectHandler)))
+		mux.Handle("GET /admin/v1/privacy/audits", pkg.BearerAuthHandler(s.config.AdminToken, pkg.HttpHandler(s.listPrivacyAuditsHandler)))
+	} else {
+		s.logger.Info("admin token is not configured, admin endpoints are disabled")
+	}
 
 	mux.HandleFunc("GET /api/v1/open-api", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/yml")
 		if _, err := w.Write(openApi); err != nil {
-			slog.ErrorContext(r.Context(), "failed to write open api", pkg.Err(err))
+			s.logger.ErrorContext(r.Context(), "failed to write open api", pkg.Err(err))
 		}
 	})
 
-	server := &http.Server{
-		Addr: s.config.ServerAddress,
-		Handler: pkg.CorrelationHandler(
+	return mux
+}
+
+// idempotent executes requests with the same Idempotency-Key at most once, when server has idempotency store
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	if s.idempotencyProcessor == nil {
+		return next
+	}
+	return pkg.IdempotencyHandler(s.idempotencyProcessor, next)
+}
+
+// Handler returns routes of the server wrapped in middlewares, requests are validated against open api spec
+func (s *Server) Handler() (http.Handler, error) {
+	validationHandler, err := pkg.OpenAPIValidationHandler(openApi, s.config.Server.ValidateResponses, s.newMux())
+	if err != nil {
+		return nil, err
+	}
+	return pkg.CorrelationHandler(
+		pkg.TracingHandler(
 			pkg.LoggingHandler(
-				mux,
+				validationHandler,
 			),
 		),
-		ReadTimeout:       100 * time.Millisecond,
-		ReadHeaderTimeout: 50 * time.Millisecond,
-		WriteTimeout:      100 * time.Millisecond,
-		IdleTimeout:       10 * time.Second,
-		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
-	}
+	), nil
+}
 
-	// everyday at midnight create additional availabilities so that at least a year of availabilities data is ready
-	c := cron.New()
-	_, err := c.AddFunc("@daily", s.CreateAvailabilities)
+func (s *Server) Run() error {
+	handler, err := s.Handler()
 	if err != nil {
 		return err
 	}
-	c.Start()
-
-	return pkg.ServeWithShutdown(server)
-}
 
-func (s *Server) CreateAvailabilities() {
-	ctx, cFunc := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cFunc()
-	products, err := s.productProcessor.ListProducts(ctx)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to list products", pkg.Err(err))
-		return
+	server := &http.Server{
+		Addr:              s.config.Server.Address,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(s.config.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(s.config.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(s.config.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(s.config.Server.IdleTimeout),
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelError),
 	}
-	// yes, n+1 but ok for this use case
-	for _, product := range products {
-		latestAvailability, err := s.availabilityProcessor.GetLatestAvailability(ctx, product.ID)
+
+	listenCtx, listenCFunc := context.WithCancel(context.Background())
+	defer listenCFunc()
+	go s.listenAvailabilityChanges(listenCtx)
+	// open streams would otherwise hold graceful shutdown until its timeout
+	server.RegisterOnShutdown(s.availabilityBroker.close)
+
+	if s.scheduler != nil {
+		// by default everyday at midnight create additional availabilities so that whole sales window of every product is ready
+		_, err = s.scheduler.AddFunc(s.config.Availability.Schedule, s.CreateAvailabilities)
 		if err != nil {
-			slog.ErrorContext(ctx, "failed to get latest availability", pkg.Err(err))
-			return
-		}
-		endDate := time.Now().UTC().AddDate(1, 0, 0).Truncate(24 * time.Hour)
-		startDate := time.Now().AddDate(0, 0, -1).UTC().Truncate(24 * time.Hour)
-		if latestAvailability != nil {
-			startDate = time.Time(latestAvailability.LocalDate)
-		}
-		daysDiff := int(endDate.Sub(startDate).Hours() / 24)
-		availabilities := make([]Availability, 0, daysDiff)
-		for i := range daysDiff {
-			availabilities = append(availabilities, Availability{
-				ID:        uuid.New(),
-				ProductID: product.ID,
-				LocalDate: JSONTime(startDate.AddDate(0, 0, i+1)),
-			})
+			return err
 		}
-		if err := s.availabilityProcessor.InsertAvailabilities(ctx, availabilities); err != nil {
-			slog.ErrorContext(ctx, "failed to insert availabilities", pkg.Err(err))
-			return
+		_, err = s.scheduler.AddFunc(idempotencyPurgeSchedule, s.PurgeIdempotencyKeys)
+		if err != nil {
+			return err
 		}
+		s.scheduler.Start()
+		s.schedulerRunning.Store(true)
+		defer func() {
+			s.schedulerRunning.Store(false)
+			s.scheduler.Stop()
+		}()
+		// fills availabilities right away on fresh deployments instead of waiting for midnight
+		go s.CreateAvailabilities()
 	}
+
+	return pkg.ServeWithShutdown(server, time.Duration(s.config.Server.ShutdownGrace))
 }
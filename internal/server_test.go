@@ -0,0 +1,608 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prathoss/hw/pkg"
+)
+
+// testClock is clock moved by tests
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock(now time.Time) *testClock {
+	return &testClock{now: now}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// testPrivacySubjectKey keys audit subjects of test servers
+const testPrivacySubjectKey = "test-privacy-subject-key-0123456789"
+
+// memoryProcessors are all processors backed by store, tests replace some of them by stubs
+func memoryProcessors(store *MemoryStore) Processors {
+	return Processors{
+		Product:      store,
+		Pricing:      store,
+		Availability: store,
+		Booking:      store,
+		Health:       store,
+		JobRun:       store,
+		Privacy:      store,
+		Idempotency:  store,
+		Locker:       store,
+	}
+}
+
+// newMemoryServer creates server backed by store without scheduler
+func newMemoryServer(t *testing.T, store *MemoryStore, options ...ServerOption) *Server {
+	t.Helper()
+	options = append([]ServerOption{
+		WithProcessors(memoryProcessors(store)),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithoutScheduler(),
+	}, options...)
+	cfg := DefaultConfig()
+	cfg.Privacy.SubjectKey = testPrivacySubjectKey
+	s, err := NewServer(cfg, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestNewServer_WithOptions(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 3}
+	store.AddProduct(product)
+
+	ids := 0
+	s := newMemoryServer(
+		t,
+		store,
+		WithClock(newTestClock(time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC))),
+		WithIDGenerator(func() uuid.UUID {
+			ids++
+			return uuid.NewSHA1(uuid.Nil, []byte{byte(ids)})
+		}),
+	)
+
+	result, err := s.GenerateAvailabilities(context.Background(), JobBackfillAvailabilities, AvailabilityGenerationParams{
+		From: JSONTime(time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sales window starts on the day of the clock and reaches 3 days ahead
+	if result.Inserted != 4 {
+		t.Fatalf("expected 4 availabilities to be generated, got %d", result.Inserted)
+	}
+	// the first ID is taken by the job run recorded by the store
+	runs, err := store.ListJobRuns(context.Background(), JobBackfillAvailabilities, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != uuid.NewSHA1(uuid.Nil, []byte{1}) {
+		t.Fatalf("expected job run to get generated ID, got %+v", runs)
+	}
+	availability, err := store.GetAvailabilityByID(context.Background(), uuid.NewSHA1(uuid.Nil, []byte{2}))
+	if err != nil {
+		t.Fatalf("expected availability to get generated ID: %v", err)
+	}
+	if !time.Time(availability.LocalDate).Equal(time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected first availability on 2024-05-20, got %s", time.Time(availability.LocalDate))
+	}
+
+	handler, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/v1/health/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var readiness Readiness
+	if err := json.NewDecoder(response.Body).Decode(&readiness); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || readiness.Status != HealthStatusUp {
+		t.Fatalf("expected server without scheduler to be ready, got %d %+v", response.StatusCode, readiness)
+	}
+	for _, check := range readiness.Checks {
+		if check.Name == "scheduler" {
+			t.Fatal("expected disabled scheduler not to be checked")
+		}
+	}
+}
+
+func TestNewServer_MissingProcessors(t *testing.T) {
+	processors := memoryProcessors(NewMemoryStore())
+	processors.Health = nil
+	processors.Locker = nil
+
+	_, err := NewServerWithProcessors(DefaultConfig(), processors)
+
+	if err == nil || err.Error() != "processors Health, Locker are not set" {
+		t.Fatalf("expected missing processors to be reported, got %v", err)
+	}
+	processors = memoryProcessors(NewMemoryStore())
+	processors.Idempotency = nil
+	if _, err := NewServerWithProcessors(DefaultConfig(), processors); err != nil {
+		t.Fatalf("expected idempotency to be optional, got %v", err)
+	}
+}
+
+func TestServer_Readiness_EmbeddedHandler(t *testing.T) {
+	// default scheduler is started only by Run, embedding only Handler must not make the server unready
+	s, err := NewServerWithProcessors(DefaultConfig(), memoryProcessors(NewMemoryStore()), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/v1/health/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var readiness Readiness
+		_ = json.NewDecoder(response.Body).Decode(&readiness)
+		t.Fatalf("expected embedded server to be ready, got %d %+v", response.StatusCode, readiness)
+	}
+}
+
+func TestServer_createBooking_SalesWindowOverTime(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 100, HorizonDays: 365, LeadTimeMinutes: 60}
+	store.AddProduct(product)
+	newYearsEve := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	availability := Availability{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(newYearsEve)}
+	if _, err := store.InsertAvailabilities(context.Background(), []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	clock := newTestClock(time.Time{})
+	s := newMemoryServer(t, store, WithClock(clock))
+
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		code string
+	}{
+		// 2024 is a leap year, new year's eve is 366 days after the previous one
+		{name: "year ahead", now: time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC), code: ProblemCodeOutsideSalesWindow},
+		{name: "horizon reached", now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "after daylight saving time ends", now: time.Date(2024, 10, 27, 2, 30, 0, 0, prague)},
+		{name: "before lead time", now: time.Date(2024, 12, 30, 22, 59, 0, 0, time.UTC)},
+		{name: "within lead time", now: time.Date(2024, 12, 30, 23, 1, 0, 0, time.UTC), code: ProblemCodeOutsideSalesWindow},
+		{name: "within lead time in local time", now: time.Date(2024, 12, 31, 0, 30, 0, 0, prague), code: ProblemCodeOutsideSalesWindow},
+		{name: "same day", now: time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC), code: ProblemCodeOutsideSalesWindow},
+		{name: "next year", now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), code: ProblemCodeAvailabilityExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Set(tt.now)
+			body := fmt.Sprintf(`{"productId": "%s", "availabilityId": "%s", "units": 1}`, product.ID, availability.ID)
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/bookings", strings.NewReader(body))
+			_, err := s.createBooking(nil, r)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("expected booking to be created, got %v", err)
+				}
+				return
+			}
+			var domainErr *pkg.DomainError
+			if !errors.As(err, &domainErr) || domainErr.Code != tt.code {
+				t.Fatalf("expected code %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestServer_createBooking_Idempotent(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	availability := Availability{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today)}
+	if _, err := store.InsertAvailabilities(context.Background(), []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	s := newMemoryServer(t, store, WithClock(newTestClock(today)))
+	handler, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	body := fmt.Sprintf(`{"productId": "%s", "availabilityId": "%s", "units": 2}`, product.ID, availability.ID)
+	bookingIDs := make([]uuid.UUID, 0, 2)
+	for range 2 {
+		r, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/bookings", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(pkg.IdempotencyKeyHeader, "booking-1")
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var booking Booking
+		err = json.NewDecoder(response.Body).Decode(&booking)
+		_ = response.Body.Close()
+		if err != nil || response.StatusCode != http.StatusOK {
+			t.Fatalf("expected booking to be created, got status %d %v", response.StatusCode, err)
+		}
+		bookingIDs = append(bookingIDs, booking.ID)
+	}
+
+	if bookingIDs[0] != bookingIDs[1] {
+		t.Fatalf("expected retry to replay the first booking, got %v", bookingIDs)
+	}
+	availabilities, err := store.GetAvailabilityTo(context.Background(), product.ID, today, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(availabilities) != 1 || availabilities[0].Vacancies != 8 {
+		t.Fatalf("expected booking to be created once, got %+v", availabilities)
+	}
+}
+
+func TestServer_getProductAvailability_ETag(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	store.SetPricing(product.ID, Pricing{Price: 1000, Currency: "EUR"})
+	clock := newTestClock(time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC))
+	today := clock.Now().Truncate(24 * time.Hour)
+	availability := Availability{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today.AddDate(0, 0, 1))}
+	if _, err := store.InsertAvailabilities(context.Background(), []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newMemoryServer(t, store, WithClock(clock)).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	get := func(path string, etag string, capability string) *http.Response {
+		t.Helper()
+		query := "?from=" + today.Format(timeFormat) + "&to=" + today.AddDate(0, 0, 7).Format(timeFormat)
+		r, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/products/"+product.ID.String()+path+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if capability != "" {
+			r.Header.Set("Capability", capability)
+		}
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+		return response
+	}
+
+	for _, path := range []string{"/availability", "/calendar"} {
+		response := get(path, "", "")
+		etag := response.Header.Get("ETag")
+		if response.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("expected %s with ETag, got %d", path, response.StatusCode)
+		}
+		if response := get(path, etag, ""); response.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected unchanged %s not to be modified, got %d", path, response.StatusCode)
+		}
+		if response := get(path, etag, CapabilityPricing); response.StatusCode != http.StatusOK {
+			t.Fatalf("expected %s with pricing to have different ETag, got %d", path, response.StatusCode)
+		}
+	}
+
+	response := get("/availability", "", CapabilityPricing)
+	etag := response.Header.Get("ETag")
+	if _, err := store.CreateBooking(context.Background(), availability, 1, BookingDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	response = get("/availability", etag, CapabilityPricing)
+	if response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+		t.Fatalf("expected booking to change ETag, got %d", response.StatusCode)
+	}
+	etag = response.Header.Get("ETag")
+	store.SetPricing(product.ID, Pricing{Price: 1200, Currency: "EUR"})
+	if response := get("/availability", etag, CapabilityPricing); response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+		t.Fatalf("expected price change to change ETag, got %d", response.StatusCode)
+	}
+}
+
+func TestServer_listAvailability_MoreProducts(t *testing.T) {
+	store := NewMemoryStore()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	products := make([]Product, 0, 3)
+	for i := range 3 {
+		product := Product{ID: uuid.New(), Name: fmt.Sprintf("product %d", i), Capacity: 10, HorizonDays: 365}
+		store.AddProduct(product)
+		store.SetPricing(product.ID, Pricing{Price: 1000, Currency: "EUR"})
+		products = append(products, product)
+	}
+	// last product has no availability in the range
+	for _, product := range products[:2] {
+		availabilities := []Availability{
+			{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today.AddDate(0, 0, 1))},
+			{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(today.AddDate(0, 0, 2))},
+		}
+		if _, err := store.InsertAvailabilities(context.Background(), availabilities); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler, err := newMemoryServer(t, store).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	post := func(selection string) *http.Response {
+		t.Helper()
+		body := fmt.Sprintf(
+			`{%s, "localDateStart": "%s", "localDateEnd": "%s"}`,
+			selection,
+			today.Format(timeFormat),
+			today.AddDate(0, 0, 7).Format(timeFormat),
+		)
+		r, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/availability", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Capability", CapabilityPricing)
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = response.Body.Close() })
+		return response
+	}
+
+	response := post(fmt.Sprintf(`"productIds": ["%s", "%s", "%s"]`, products[2].ID, products[0].ID, products[2].ID))
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected availabilities of products, got %d", response.StatusCode)
+	}
+	var groups []ProductAvailabilities[PricedAvailability]
+	if err := json.NewDecoder(response.Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].ProductID != products[2].ID || groups[1].ProductID != products[0].ID {
+		t.Fatalf("expected 2 distinct products in requested order, got %+v", groups)
+	}
+	for _, group := range groups {
+		expected := 2
+		if group.ProductID == products[2].ID {
+			expected = 0
+		}
+		if len(group.Availabilities) != expected {
+			t.Fatalf("expected %d availabilities of product %s, got %+v", expected, group.ProductID, group.Availabilities)
+		}
+		for _, availability := range group.Availabilities {
+			if availability.Price != 1000 {
+				t.Fatalf("expected priced availabilities, got %+v", availability)
+			}
+		}
+	}
+
+	response = post(`"allProducts": true`)
+	if err := json.NewDecoder(response.Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || len(groups) != 3 {
+		t.Fatalf("expected all 3 products, got %d %+v", response.StatusCode, groups)
+	}
+
+	if response := post(fmt.Sprintf(`"productIds": ["%s"]`, uuid.New())); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unknown product to be not found, got %d", response.StatusCode)
+	}
+}
+
+func TestServer_listProductsAvailability_ProductDaysLimit(t *testing.T) {
+	store := NewMemoryStore()
+	for i := range maxAvailabilityProductDays/(maxAvailabilityRangeDays+1) + 1 {
+		store.AddProduct(Product{ID: uuid.New(), Name: fmt.Sprintf("product %d", i), Capacity: 10, HorizonDays: 365})
+	}
+	s := newMemoryServer(t, store)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := s.listProductsAvailability(context.Background(), nil, from, from.AddDate(0, 0, maxAvailabilityRangeDays), "")
+	var badRequest *pkg.BadRequestError
+	if !errors.As(err, &badRequest) {
+		t.Fatalf("expected all products for the longest range to be rejected, got %v", err)
+	}
+	if _, err := s.listProductsAvailability(context.Background(), nil, from, from.AddDate(0, 0, 30), ""); err != nil {
+		t.Fatalf("expected all products for a month to be returned, got %v", err)
+	}
+}
+
+func TestServer_updateBooking(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	availability := Availability{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1))}
+	if _, err := store.InsertAvailabilities(context.Background(), []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newMemoryServer(t, store).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	send := func(method string, path string, body string) (*http.Response, Booking) {
+		t.Helper()
+		r, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		var booking Booking
+		if response.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(response.Body).Decode(&booking); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return response, booking
+	}
+
+	response, booking := send(http.MethodPost, "/api/v1/bookings", fmt.Sprintf(
+		`{"productId": "%s", "availabilityId": "%s", "units": 1, "contact": {"fullName": "Jan Novák", "emailAddress": "jan@example.com"}, "resellerReference": "R-1"}`,
+		product.ID,
+		availability.ID,
+	))
+	if response.StatusCode != http.StatusOK || booking.Contact.EmailAddress != "jan@example.com" || booking.ResellerReference != "R-1" {
+		t.Fatalf("expected booking with contact, got %d %+v", response.StatusCode, booking)
+	}
+
+	path := "/api/v1/bookings/" + booking.ID.String()
+	response, booking = send(http.MethodPatch, path, `{"contact": {"phoneNumber": "+420123456789"}, "notes": "late arrival"}`)
+	expected := BookingDetails{
+		Contact:           Contact{FullName: "Jan Novák", EmailAddress: "jan@example.com", PhoneNumber: "+420123456789"},
+		Notes:             "late arrival",
+		ResellerReference: "R-1",
+	}
+	if response.StatusCode != http.StatusOK || booking.BookingDetails != expected {
+		t.Fatalf("expected patch to be merged into details %+v, got %d %+v", expected, response.StatusCode, booking.BookingDetails)
+	}
+
+	if response, _ := send(http.MethodPatch, path, `{"contact": {"emailAddress": "jan"}}`); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid email to be rejected, got %d", response.StatusCode)
+	}
+	if response, _ := send(http.MethodPatch, path, `{"units": 2}`); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected units not to be updatable, got %d", response.StatusCode)
+	}
+	if response, _ := send(http.MethodPatch, "/api/v1/bookings/"+uuid.New().String(), `{"notes": ""}`); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unknown booking to be not found, got %d", response.StatusCode)
+	}
+}
+
+func TestServer_EraseDataSubject(t *testing.T) {
+	store := NewMemoryStore()
+	product := Product{ID: uuid.New(), Name: "product", Capacity: 10, HorizonDays: 365}
+	store.AddProduct(product)
+	availability := Availability{ID: uuid.New(), ProductID: product.ID, LocalDate: JSONTime(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))}
+	if _, err := store.InsertAvailabilities(context.Background(), []Availability{availability}); err != nil {
+		t.Fatal(err)
+	}
+	booking, err := store.CreateBooking(context.Background(), availability, 1, BookingDetails{Contact: Contact{FullName: "Jan Novák", EmailAddress: "jan@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newTestClock(time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC))
+	s := newMemoryServer(t, store, WithClock(clock))
+	ctx := context.Background()
+
+	export, err := s.ExportDataSubject(ctx, "Jan@Example.com", PrivacySourceAdminAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Bookings) != 1 || export.Bookings[0].ID != booking.ID || !export.ExportedAt.Equal(clock.Now()) {
+		t.Fatalf("expected booking to be exported, got %+v", export)
+	}
+	clock.Set(clock.Now().Add(time.Minute))
+	erasure, err := s.EraseDataSubject(ctx, "jan@example.com", PrivacySourceCLI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(erasure.BookingIDs) != 1 {
+		t.Fatalf("expected booking to be erased, got %+v", erasure)
+	}
+	clock.Set(clock.Now().Add(time.Minute))
+	if export, err := s.ExportDataSubject(ctx, "jan@example.com", PrivacySourceCLI); err != nil || len(export.Bookings) != 0 {
+		t.Fatalf("expected nothing to be exported after erasure, got %+v %v", export, err)
+	}
+	_, err = s.EraseDataSubject(ctx, "Jan <jan@example.com>", PrivacySourceCLI)
+	var badRequest *pkg.BadRequestError
+	if !errors.As(err, &badRequest) {
+		t.Fatalf("expected invalid email address to be rejected, got %v", err)
+	}
+
+	audits, err := s.ListPrivacyAudits(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) != 3 || audits[1].Action != PrivacyActionErasure || audits[1].Source != PrivacySourceCLI {
+		t.Fatalf("expected export, erasure and export to be audited, got %+v", audits)
+	}
+	for _, audit := range audits {
+		if audit.Subject != privacySubject(testPrivacySubjectKey, "jan@example.com") {
+			t.Fatalf("expected audit of the same subject regardless of email case, got %+v", audit)
+		}
+	}
+}
+
+func TestServer_DataSubjectRequests(t *testing.T) {
+	store := NewMemoryStore()
+	s := newMemoryServer(t, store)
+
+	request := httptest.NewRequest(http.MethodPost, "/admin/v1/privacy/erase", strings.NewReader(`{"emailAddress": "jan@example.com", "email": "jan@example.com"}`))
+	_, err := s.eraseDataSubjectHandler(nil, request)
+	var badRequest *pkg.BadRequestError
+	if !errors.As(err, &badRequest) {
+		t.Fatalf("expected unknown field to be rejected, got %v", err)
+	}
+
+	s.config.Privacy.SubjectKey = ""
+	_, err = s.ExportDataSubject(context.Background(), "jan@example.com", PrivacySourceCLI)
+	var unavailable *pkg.ServiceUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected request to be rejected without subject key, got %v", err)
+	}
+	if audits, _ := store.ListPrivacyAudits(context.Background(), 10); len(audits) != 0 {
+		t.Fatalf("expected rejected request not to be audited, got %+v", audits)
+	}
+	if privacySubject("key-a", "jan@example.com") == privacySubject("key-b", "jan@example.com") {
+		t.Fatal("expected subject to depend on the key")
+	}
+}
@@ -0,0 +1,67 @@
+// Package testdb starts PostgreSQL in a container for tests of repositories and the client
+package testdb
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Migrate applies migrations to database with dsn, it is passed in so that testdb does not depend on internal
+type Migrate func(ctx context.Context, dsn string) error
+
+// Setup starts PostgreSQL with the application user and database, migrates it and returns DSN of the application
+// user, cleanup terminates the container
+func Setup(migrate Migrate) (string, func(), error) {
+	testcontainers.Logger = log.New(&ioutils.NopWriter{}, "", 0)
+	ctx := context.Background()
+	pgContainer, err := postgres.RunContainer(
+		ctx,
+		testcontainers.WithImage("postgres:15-alpine3.17"),
+		postgres.WithInitScripts(initScriptPath()),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second),
+		),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		_ = pgContainer.Terminate(ctx)
+	}
+	connString, err := pgContainer.ConnectionString(ctx, "sslmode=disable", "search_path=ventrata")
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	urlForTest, err := url.Parse(connString)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	urlForTest.User = url.UserPassword("ventrata_usr", "ventrata123")
+	urlForTest.Path = "ventrata"
+
+	if err := migrate(ctx, urlForTest.String()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return urlForTest.String(), cleanup, nil
+}
+
+// initScriptPath resolves the script from the source file, so that tests of any package find it
+func initScriptPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", ".docker", "db", "001_create_user_db.sql")
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"log/slog"
+
 	"github.com/prathoss/hw/cmd"
 	"github.com/prathoss/hw/pkg"
 )
 
 func main() {
-	pkg.SetupLogger()
+	// defaults until commands load config
+	pkg.SetupLogger(pkg.LoggingConfig{Format: pkg.LogFormatText, Level: slog.LevelInfo})
 	cmd.Execute()
 }
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS bookings_availability_changed ON ventrata.bookings;
+DROP FUNCTION IF EXISTS ventrata.notify_availability_changed();
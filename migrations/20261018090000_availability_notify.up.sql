@@ -0,0 +1,14 @@
+CREATE OR REPLACE FUNCTION ventrata.notify_availability_changed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('availability_changed', OLD.availability_id::text);
+        RETURN OLD;
+    END IF;
+    PERFORM pg_notify('availability_changed', NEW.availability_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bookings_availability_changed
+    AFTER INSERT OR UPDATE OR DELETE ON ventrata.bookings
+    FOR EACH ROW EXECUTE FUNCTION ventrata.notify_availability_changed();
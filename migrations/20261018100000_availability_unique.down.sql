@@ -0,0 +1 @@
+ALTER TABLE ventrata.availability DROP CONSTRAINT IF EXISTS availability_product_date_key;
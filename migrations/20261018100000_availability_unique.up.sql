@@ -0,0 +1,41 @@
+-- replicas might have generated the same day multiple times, rows have no creation time, so bookings are moved
+-- to the copy of the day with most tickets (ties broken by id) and the other copies are deleted
+CREATE TEMPORARY TABLE availability_duplicates ON COMMIT DROP AS
+SELECT a.id, first_value(a.id) OVER (
+    PARTITION BY a.product_id, a.date
+    ORDER BY (SELECT count(*) FROM ventrata.bookings b JOIN ventrata.tickets t ON b.id = t.booking_id WHERE b.availability_id = a.id) DESC, a.id
+) AS keep_id
+FROM ventrata.availability a;
+
+UPDATE ventrata.bookings b
+SET availability_id = d.keep_id
+FROM availability_duplicates d
+WHERE b.availability_id = d.id AND d.id <> d.keep_id;
+
+DELETE FROM ventrata.availability a
+USING availability_duplicates d
+WHERE a.id = d.id AND d.id <> d.keep_id;
+
+-- merged day might have more tickets than capacity of its product, it has to be resolved manually
+-- (e.g. by moving bookings to another day), so the migration fails and nothing is changed
+DO $$
+DECLARE
+    overbooked record;
+BEGIN
+    SELECT a.product_id, a.date, p.capacity, count(t.id) AS tickets INTO overbooked
+    FROM ventrata.availability a
+    JOIN ventrata.products p ON p.id = a.product_id
+    JOIN ventrata.bookings b ON b.availability_id = a.id
+    JOIN ventrata.tickets t ON t.booking_id = b.id
+    WHERE a.id IN (SELECT keep_id FROM availability_duplicates WHERE id <> keep_id)
+    GROUP BY a.id, a.product_id, a.date, p.capacity
+    HAVING count(t.id) > p.capacity
+    LIMIT 1;
+    IF FOUND THEN
+        RAISE EXCEPTION 'merged availability of product % on % has % tickets over capacity %',
+            overbooked.product_id, overbooked.date, overbooked.tickets, overbooked.capacity;
+    END IF;
+END
+$$;
+
+ALTER TABLE ventrata.availability ADD CONSTRAINT availability_product_date_key UNIQUE (product_id, date);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS ventrata.job_runs (
+    id uuid PRIMARY KEY,
+    job text NOT NULL,
+    started_at timestamptz NOT NULL,
+    finished_at timestamptz,
+    rows_inserted bigint,
+    error text
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_job_started_at_idx ON ventrata.job_runs (job, started_at DESC);
@@ -0,0 +1,4 @@
+ALTER TABLE ventrata.products
+    DROP COLUMN IF EXISTS horizon_days,
+    DROP COLUMN IF EXISTS lead_time_minutes,
+    DROP COLUMN IF EXISTS sales_cutoff;
@@ -0,0 +1,4 @@
+ALTER TABLE ventrata.products
+    ADD COLUMN IF NOT EXISTS horizon_days integer NOT NULL DEFAULT 365 CHECK (horizon_days >= 0),
+    ADD COLUMN IF NOT EXISTS lead_time_minutes integer NOT NULL DEFAULT 0 CHECK (lead_time_minutes >= 0),
+    ADD COLUMN IF NOT EXISTS sales_cutoff date;
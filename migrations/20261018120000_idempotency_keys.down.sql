@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ventrata.idempotency_keys;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS ventrata.idempotency_keys (
+    key text PRIMARY KEY,
+    fingerprint text NOT NULL,
+    -- status and response are null while the request is in progress
+    status integer,
+    content_type text,
+    body bytea,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ventrata.tickets_booking_id_idx;
+DROP INDEX IF EXISTS ventrata.bookings_availability_id_idx;
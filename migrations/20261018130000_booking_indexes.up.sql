@@ -0,0 +1,3 @@
+-- availability aggregates join bookings and tickets by their parents
+CREATE INDEX IF NOT EXISTS bookings_availability_id_idx ON ventrata.bookings (availability_id);
+CREATE INDEX IF NOT EXISTS tickets_booking_id_idx ON ventrata.tickets (booking_id);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS bookings_move_booked ON ventrata.bookings;
+DROP FUNCTION IF EXISTS ventrata.move_booking_booked();
+DROP TRIGGER IF EXISTS tickets_count_booked ON ventrata.tickets;
+DROP FUNCTION IF EXISTS ventrata.count_ticket_booked();
+ALTER TABLE ventrata.availability DROP COLUMN IF EXISTS booked;
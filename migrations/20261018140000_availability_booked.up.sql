@@ -0,0 +1,52 @@
+-- booked is the number of tickets of the availability, vacancies are its product capacity minus booked
+ALTER TABLE ventrata.availability ADD COLUMN IF NOT EXISTS booked integer NOT NULL DEFAULT 0;
+
+UPDATE ventrata.availability a
+SET booked = c.tickets
+FROM (
+    SELECT b.availability_id, count(*) AS tickets
+    FROM ventrata.bookings b
+    JOIN ventrata.tickets t ON b.id = t.booking_id
+    GROUP BY b.availability_id
+) c
+WHERE a.id = c.availability_id;
+
+ALTER TABLE ventrata.availability ADD CONSTRAINT availability_booked_check CHECK (booked >= 0);
+
+-- counters are changed in the same transaction that adds or removes tickets, whichever code path does it
+CREATE OR REPLACE FUNCTION ventrata.count_ticket_booked() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('DELETE', 'UPDATE') THEN
+        UPDATE ventrata.availability SET booked = booked - 1
+        WHERE id = (SELECT availability_id FROM ventrata.bookings WHERE id = OLD.booking_id);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE ventrata.availability SET booked = booked + 1
+        WHERE id = (SELECT availability_id FROM ventrata.bookings WHERE id = NEW.booking_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tickets_count_booked
+    AFTER INSERT OR DELETE OR UPDATE OF booking_id ON ventrata.tickets
+    FOR EACH ROW EXECUTE FUNCTION ventrata.count_ticket_booked();
+
+-- booking moved to another availability moves its tickets with it
+CREATE OR REPLACE FUNCTION ventrata.move_booking_booked() RETURNS trigger AS $$
+DECLARE
+    tickets integer;
+BEGIN
+    IF NEW.availability_id IS NOT DISTINCT FROM OLD.availability_id THEN
+        RETURN NULL;
+    END IF;
+    SELECT count(*) INTO tickets FROM ventrata.tickets WHERE booking_id = NEW.id;
+    UPDATE ventrata.availability SET booked = booked - tickets WHERE id = OLD.availability_id;
+    UPDATE ventrata.availability SET booked = booked + tickets WHERE id = NEW.availability_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bookings_move_booked
+    AFTER UPDATE OF availability_id ON ventrata.bookings
+    FOR EACH ROW EXECUTE FUNCTION ventrata.move_booking_booked();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ventrata.booking_notes;
+DROP TABLE IF EXISTS ventrata.booking_contacts;
@@ -0,0 +1,15 @@
+-- optional details of booking, they are kept apart from bookings so that they can be erased on their own
+CREATE TABLE IF NOT EXISTS ventrata.booking_contacts (
+    booking_id uuid PRIMARY KEY REFERENCES bookings(id) ON DELETE CASCADE,
+    full_name text NOT NULL DEFAULT '',
+    email_address text NOT NULL DEFAULT '',
+    phone_number text NOT NULL DEFAULT '',
+    locale text NOT NULL DEFAULT '',
+    country text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS ventrata.booking_notes (
+    booking_id uuid PRIMARY KEY REFERENCES bookings(id) ON DELETE CASCADE,
+    notes text NOT NULL DEFAULT '',
+    reseller_reference text NOT NULL DEFAULT ''
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ventrata.privacy_audits;
+DROP INDEX IF EXISTS ventrata.booking_contacts_email_address_idx;
@@ -0,0 +1,13 @@
+-- data-subject requests find bookings by email address regardless of its case
+CREATE INDEX IF NOT EXISTS booking_contacts_email_address_idx ON ventrata.booking_contacts (lower(email_address));
+
+CREATE TABLE IF NOT EXISTS ventrata.privacy_audits (
+    id uuid PRIMARY KEY,
+    action text NOT NULL,
+    subject text NOT NULL,
+    source text NOT NULL,
+    bookings integer NOT NULL,
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS privacy_audits_created_at_idx ON ventrata.privacy_audits (created_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE ventrata.idempotency_keys DROP COLUMN IF EXISTS locked_until;
@@ -0,0 +1,2 @@
+-- in-progress key is reclaimed by a retry of the same request once its lease ends, e.g. after a crash
+ALTER TABLE ventrata.idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamptz;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS pricing_bump_version ON ventrata.pricing;
+DROP FUNCTION IF EXISTS ventrata.bump_pricing_version();
+ALTER TABLE ventrata.pricing DROP COLUMN IF EXISTS version;
+DROP SEQUENCE IF EXISTS ventrata.pricing_version_seq;
@@ -0,0 +1,15 @@
+-- version changes with every change of pricing, cached availability is revalidated by it without pricing the body
+CREATE SEQUENCE IF NOT EXISTS ventrata.pricing_version_seq;
+
+ALTER TABLE ventrata.pricing ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT nextval('ventrata.pricing_version_seq');
+
+CREATE OR REPLACE FUNCTION ventrata.bump_pricing_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version = nextval('ventrata.pricing_version_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER pricing_bump_version
+    BEFORE UPDATE ON ventrata.pricing
+    FOR EACH ROW EXECUTE FUNCTION ventrata.bump_pricing_version();
@@ -0,0 +1,55 @@
+// Package migrations embeds SQL migrations, so that the binary does not depend on migrations directory
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// List returns embedded migrations ordered by version
+func List() ([]Migration, error) {
+	// glob returns files in lexical order, which is the version order for timestamp versions
+	entries, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		versionStr, name, ok := strings.Cut(strings.TrimSuffix(entry, ".up.sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s has no version prefix", entry)
+		}
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has malformed version: %w", entry, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: uint(version),
+			Name:    name,
+		})
+	}
+	return migrations, nil
+}
+
+// LatestVersion returns version of the newest up migration
+func LatestVersion() (uint, error) {
+	migrations, err := List()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// BearerAuthHandler lets through only requests with Authorization header carrying token, requests without token
+// are unauthorized, requests with other token are forbidden
+func BearerAuthHandler(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			WriteError(w, r, NewUnauthorizedError("Missing bearer token"))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			WriteError(w, r, NewForbiddenError("Bearer token is not allowed to access this resource"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CacheHandler makes successful GET responses of next cacheable by Cache-Control cacheControl, handlers of next
+// validate requests with CheckETag before building the body, ETag is dropped from responses that are not successful
+func CacheHandler(cacheControl string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&cacheResponseWriter{ResponseWriter: w, cacheControl: cacheControl}, r)
+	})
+}
+
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	wroteHeader  bool
+}
+
+func (c *cacheResponseWriter) WriteHeader(statusCode int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		if statusCode == http.StatusOK || statusCode == http.StatusNotModified {
+			c.Header().Set("Cache-Control", c.cacheControl)
+		} else {
+			c.Header().Del("ETag")
+		}
+	}
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *cacheResponseWriter) Write(bytes []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(bytes)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (c *cacheResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// CheckETag sets strong ETag computed from validators, versions of data the response is built from, so that request
+// is validated before the body is built, request with matching If-None-Match gets NotModifiedError
+func CheckETag(w http.ResponseWriter, r *http.Request, validators ...string) error {
+	hash := sha256.New()
+	for _, validator := range validators {
+		hash.Write([]byte(validator))
+		hash.Write([]byte{0})
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return &NotModifiedError{}
+	}
+	return nil
+}
+
+var _ error = &NotModifiedError{}
+var _ HttpProblemWriter = &NotModifiedError{}
+
+// NotModifiedError is written as 304 Not Modified without body, cached response of the client is still valid
+type NotModifiedError struct{}
+
+func (n *NotModifiedError) Error() string {
+	return "not modified"
+}
+
+func (n *NotModifiedError) WriteProblem(w http.ResponseWriter, _ *http.Request) error {
+	// representation headers are not sent with 304
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return nil
+}
+
+// etagMatches reports whether If-None-Match header lists etag, it uses weak comparison as required for If-None-Match
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
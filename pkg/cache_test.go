@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheHandler(t *testing.T) {
+	version := "1"
+	built := 0
+	handler := CacheHandler("public, max-age=10", HttpHandler(func(w http.ResponseWriter, r *http.Request) (any, error) {
+		if version == "" {
+			return nil, NewDomainError(ErrNotFound, ProblemCodeNotFound, "not found")
+		}
+		if err := CheckETag(w, r, version); err != nil {
+			return nil, err
+		}
+		built++
+		return map[string]string{"version": version}, nil
+	}))
+	send := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/availability", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=10" {
+		t.Fatalf("expected cacheable response, got %d %v", w.Code, w.Header())
+	}
+	w = send(`"other", W/` + etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag || w.Header().Get("Content-Type") != "" {
+		t.Fatalf("expected 304 for matching ETag, got %d %s", w.Code, w.Body.String())
+	}
+	if built != 1 {
+		t.Fatalf("expected body not to be built for 304, built %d times", built)
+	}
+
+	version = "2"
+	if w := send(etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected changed version to get new ETag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	version = ""
+	if w := send(etag); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Fatalf("expected failed response not to be cacheable, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCheckETag(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/availability", nil)
+	w := httptest.NewRecorder()
+	if err := CheckETag(w, r, "a", "bc"); err != nil {
+		t.Fatal(err)
+	}
+	etag := w.Header().Get("ETag")
+
+	r.Header.Set("If-None-Match", etag)
+	if err := CheckETag(httptest.NewRecorder(), r, "ab", "c"); err != nil {
+		t.Fatalf("expected validators not to be ambiguous when concatenated, got %v", err)
+	}
+	var notModified *NotModifiedError
+	if err := CheckETag(httptest.NewRecorder(), r, "a", "bc"); !errors.As(err, &notModified) {
+		t.Fatalf("expected matching ETag to be not modified, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"errors"
+)
+
+// Kinds of domain errors, repositories return errors matching one of them with errors.Is
+// and WriteError maps them to problems
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrGone       = errors.New("gone")
+	ErrValidation = errors.New("validation failed")
+)
+
+var _ error = &DomainError{}
+
+// NewDomainError creates error of kind (one of ErrNotFound, ErrConflict, ErrGone, ErrValidation) identified by code
+func NewDomainError(kind error, code string, message string, invalidParams ...InvalidParam) *DomainError {
+	return &DomainError{
+		kind:          kind,
+		Code:          code,
+		Message:       message,
+		InvalidParams: invalidParams,
+	}
+}
+
+type DomainError struct {
+	kind    error
+	Code    string
+	Message string
+	// InvalidParams are reported with ErrValidation
+	InvalidParams []InvalidParam
+}
+
+func (d *DomainError) Error() string {
+	return d.Message
+}
+
+func (d *DomainError) Is(target error) bool {
+	return target == d.kind
+}
+
+// problemFromError maps err to problem, errors that are neither problems nor domain errors are internal server errors
+func problemFromError(err error) HttpProblemWriter {
+	var problemWriter HttpProblemWriter
+	if errors.As(err, &problemWriter) {
+		return problemWriter
+	}
+
+	code := ""
+	message := err.Error()
+	var invalidParams []InvalidParam
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		code = domainErr.Code
+		message = domainErr.Message
+		invalidParams = domainErr.InvalidParams
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &NotFoundError{code: orDefault(code, ProblemCodeNotFound), message: message}
+	case errors.Is(err, ErrConflict):
+		return NewConflictError(orDefault(code, ProblemCodeConflict), message)
+	case errors.Is(err, ErrGone):
+		return NewGoneError(orDefault(code, ProblemCodeGone), message)
+	case errors.Is(err, ErrValidation):
+		return NewUnprocessableEntityError(orDefault(code, ProblemCodeUnprocessable), message, invalidParams...)
+	default:
+		return NewInternalServerError(err)
+	}
+}
+
+func orDefault(code string, defaultCode string) string {
+	if code == "" {
+		return defaultCode
+	}
+	return code
+}
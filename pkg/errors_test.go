@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError_DomainErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{
+			name:   "wrapped not found",
+			err:    fmt.Errorf("loading product failed: %w", NewDomainError(ErrNotFound, "PRODUCT_NOT_FOUND", "product not found")),
+			status: http.StatusNotFound,
+			code:   "PRODUCT_NOT_FOUND",
+		},
+		{
+			name:   "conflict",
+			err:    NewDomainError(ErrConflict, "AVAILABILITY_SOLD_OUT", "sold out"),
+			status: http.StatusConflict,
+			code:   "AVAILABILITY_SOLD_OUT",
+		},
+		{
+			name:   "gone",
+			err:    NewDomainError(ErrGone, "AVAILABILITY_EXPIRED", "passed"),
+			status: http.StatusGone,
+			code:   "AVAILABILITY_EXPIRED",
+		},
+		{
+			name:   "validation",
+			err:    NewDomainError(ErrValidation, "OUTSIDE_SALES_WINDOW", "outside", InvalidParam{Name: "availabilityId", Reason: "too late"}),
+			status: http.StatusUnprocessableEntity,
+			code:   "OUTSIDE_SALES_WINDOW",
+		},
+		{
+			name:   "plain sentinel",
+			err:    fmt.Errorf("job run %d: %w", 1, ErrNotFound),
+			status: http.StatusNotFound,
+			code:   ProblemCodeNotFound,
+		},
+		{
+			name:   "joined",
+			err:    errors.Join(NewDomainError(ErrConflict, "BOOKING_ALREADY_CONFIRMED", "confirmed"), errors.New("recording failed")),
+			status: http.StatusConflict,
+			code:   "BOOKING_ALREADY_CONFIRMED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			WriteError(w, httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil), tt.err)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			var detail ProblemDetail
+			if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			if detail.Code != tt.code {
+				t.Fatalf("expected code %s, got %s", tt.code, detail.Code)
+			}
+		})
+	}
+}
@@ -11,9 +11,12 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-func ServeWithShutdown(s *http.Server) error {
+// ServeWithShutdown serves until interrupted, then waits at most shutdownGrace for in-flight requests
+func ServeWithShutdown(s *http.Server, shutdownGrace time.Duration) error {
 	ctx := context.Background()
 	ctx, cFunc := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cFunc()
@@ -30,7 +33,7 @@ func ServeWithShutdown(s *http.Server) error {
 	select {
 	case <-ctx.Done():
 		slog.Info("Server is shutting down")
-		shutdownCtx, shutdownCFunc := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, shutdownCFunc := context.WithTimeout(context.Background(), shutdownGrace)
 		defer shutdownCFunc()
 		if err := s.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("server shutdown failed: %w", err)
@@ -46,17 +49,7 @@ type HttpHandler func(w http.ResponseWriter, r *http.Request) (any, error)
 func (f HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	responseModel, err := f(w, r)
 	if err != nil {
-		if problemWriter, ok := err.(HttpProblemWriter); ok {
-			if err := problemWriter.WriteProblem(r.Context(), w); err != nil {
-				slog.ErrorContext(r.Context(), "response could not be written", Err(err))
-			}
-		} else {
-			internalServerError := NewInternalServerError(err)
-			err := internalServerError.WriteProblem(r.Context(), w)
-			if err != nil {
-				slog.ErrorContext(r.Context(), "response could not be written", Err(err))
-			}
-		}
+		WriteError(w, r, err)
 		return
 	}
 
@@ -71,10 +64,25 @@ func (f HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// WriteError writes err as a problem detail, domain errors are mapped to problems of their kind,
+// other errors not implementing HttpProblemWriter are written as internal server error
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	if err := problemFromError(err).WriteProblem(w, r); err != nil {
+		slog.ErrorContext(r.Context(), "response could not be written", Err(err))
+	}
+}
+
 type ProblemDetail struct {
 	Status int    `json:"status"`
 	Type   string `json:"type"`
 	Title  string `json:"title"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is path of the request the problem occurred on
+	Instance string `json:"instance,omitempty"`
+	// Code is machine-readable code of the problem type, clients are expected to branch on it
+	Code          string    `json:"code"`
+	CorrelationID uuid.UUID `json:"correlationId"`
 }
 
 type InvalidParam struct {
@@ -88,7 +96,7 @@ type ValidationProblemDetail struct {
 }
 
 type HttpProblemWriter interface {
-	WriteProblem(ctx context.Context, w http.ResponseWriter) error
+	WriteProblem(w http.ResponseWriter, r *http.Request) error
 }
 
 var _ error = &ServiceUnavailableError{}
@@ -104,16 +112,10 @@ type ServiceUnavailableError struct {
 	innerError error
 }
 
-func (s *ServiceUnavailableError) WriteProblem(ctx context.Context, w http.ResponseWriter) error {
-	slog.ErrorContext(ctx, "request resulted in a service unavailable", Err(s.innerError))
-	w.WriteHeader(http.StatusServiceUnavailable)
-	w.Header().Set("Content-Type", "application/problem+json")
-	detail := ProblemDetail{
-		Status: http.StatusServiceUnavailable,
-		Type:   "https://datatracker.ietf.org/doc/html/rfc7231#section-6.6.4",
-		Title:  "The server is unavailable",
-	}
-	return json.NewEncoder(w).Encode(detail)
+func (s *ServiceUnavailableError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	slog.ErrorContext(r.Context(), "request resulted in a service unavailable", Err(s.innerError))
+	detail := NewProblemDetail(r, http.StatusServiceUnavailable, ProblemCodeServiceUnavailable, "The server is unavailable", "")
+	return writeProblem(w, detail.Status, detail)
 }
 
 func (s *ServiceUnavailableError) Error() string {
@@ -135,18 +137,12 @@ func (b *BadRequestError) Error() string {
 	return "Request is invalid"
 }
 
-func (b *BadRequestError) WriteProblem(_ context.Context, w http.ResponseWriter) error {
-	w.WriteHeader(http.StatusBadRequest)
-	w.Header().Set("Content-Type", "application/problem+json")
+func (b *BadRequestError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
 	detail := ValidationProblemDetail{
-		ProblemDetail: ProblemDetail{
-			Status: http.StatusBadRequest,
-			Type:   "https://datatracker.ietf.org/doc/html/rfc7231#section-6.5.1",
-			Title:  "Request parameters did not validate",
-		},
+		ProblemDetail: NewProblemDetail(r, http.StatusBadRequest, ProblemCodeValidationFailed, "Request parameters did not validate", ""),
 		InvalidParams: b.invalidParams,
 	}
-	return json.NewEncoder(w).Encode(detail)
+	return writeProblem(w, detail.Status, detail)
 }
 
 var _ error = &InternalServerError{}
@@ -166,16 +162,10 @@ func (i *InternalServerError) Error() string {
 	return i.innerError.Error()
 }
 
-func (i *InternalServerError) WriteProblem(ctx context.Context, w http.ResponseWriter) error {
-	slog.ErrorContext(ctx, "request resulted in a internal server error", Err(i.innerError))
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Header().Set("Content-Type", "application/problem+json")
-	detail := ProblemDetail{
-		Status: http.StatusInternalServerError,
-		Type:   "https://datatracker.ietf.org/doc/html/rfc7231#section-6.6.1",
-		Title:  "Internal Server Error",
-	}
-	return json.NewEncoder(w).Encode(detail)
+func (i *InternalServerError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	slog.ErrorContext(r.Context(), "request resulted in a internal server error", Err(i.innerError))
+	detail := NewProblemDetail(r, http.StatusInternalServerError, ProblemCodeInternal, "Internal Server Error", "")
+	return writeProblem(w, detail.Status, detail)
 }
 
 var _ error = &NotFoundError{}
@@ -183,11 +173,13 @@ var _ HttpProblemWriter = &NotFoundError{}
 
 func NewNotFoundError(message string) *NotFoundError {
 	return &NotFoundError{
+		code:    ProblemCodeNotFound,
 		message: message,
 	}
 }
 
 type NotFoundError struct {
+	code    string
 	message string
 }
 
@@ -195,13 +187,30 @@ func (n *NotFoundError) Error() string {
 	return n.message
 }
 
-func (n *NotFoundError) WriteProblem(ctx context.Context, w http.ResponseWriter) error {
-	w.WriteHeader(http.StatusNotFound)
-	w.Header().Set("Content-Type", "application/problem+json")
-	detail := ProblemDetail{
-		Status: http.StatusNotFound,
-		Type:   "https://datatracker.ietf.org/doc/html/rfc7231#section-6.5.4",
-		Title:  n.message,
+func (n *NotFoundError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	detail := NewProblemDetail(r, http.StatusNotFound, n.code, "Resource was not found", n.message)
+	return writeProblem(w, detail.Status, detail)
+}
+
+var _ error = &UnauthorizedError{}
+var _ HttpProblemWriter = &UnauthorizedError{}
+
+func NewUnauthorizedError(message string) *UnauthorizedError {
+	return &UnauthorizedError{
+		message: message,
 	}
-	return json.NewEncoder(w).Encode(detail)
+}
+
+type UnauthorizedError struct {
+	message string
+}
+
+func (u *UnauthorizedError) Error() string {
+	return u.message
+}
+
+func (u *UnauthorizedError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	detail := NewProblemDetail(r, http.StatusUnauthorized, ProblemCodeUnauthorized, "Authentication is required", u.message)
+	return writeProblem(w, detail.Status, detail)
 }
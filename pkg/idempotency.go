@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from an earlier request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey reserves key for request identified by fingerprint, response is returned when request
+	// with the key has already completed. Reusing key for a different request is ErrValidation, reusing key
+	// of a request still in progress is ErrConflict.
+	ReserveIdempotencyKey(ctx context.Context, key string, fingerprint string) (*IdempotentResponse, error)
+	// CompleteIdempotencyKey stores response of the request with key
+	CompleteIdempotencyKey(ctx context.Context, key string, response IdempotentResponse) error
+	// ReleaseIdempotencyKey forgets key of failed request, so that it can be retried
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// IdempotencyHandler executes requests carrying Idempotency-Key at most once, successful responses are stored
+// and replayed to retries with the same key. Requests without the key are passed to next as they are.
+func IdempotencyHandler(store IdempotencyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			WriteError(w, r, NewBadRequestError(InvalidParam{Name: "Body", Reason: err.Error()}))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := store.ReserveIdempotencyKey(r.Context(), key, requestFingerprint(r, body))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if stored != nil {
+			w.Header().Set("Content-Type", stored.ContentType)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			_, _ = w.Write(stored.Body)
+			return
+		}
+
+		buffer := newBufferedResponseWriter()
+		next.ServeHTTP(buffer, r)
+		// request context might be cancelled already, the key must not stay reserved
+		ctx := context.WithoutCancel(r.Context())
+		if buffer.status >= 200 && buffer.status < 300 {
+			err = store.CompleteIdempotencyKey(ctx, key, IdempotentResponse{
+				Status:      buffer.status,
+				ContentType: buffer.Header().Get("Content-Type"),
+				Body:        buffer.body.Bytes(),
+			})
+		} else {
+			err = store.ReleaseIdempotencyKey(ctx, key)
+		}
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		buffer.writeTo(w)
+	})
+}
+
+// requestFingerprint identifies request so that key reused for a different request can be detected,
+// capability is part of it as it changes shape of the response
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n" + r.Header.Get("Capability") + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIdempotencyHandler(t *testing.T) {
+	store := &memoryIdempotencyStore{responses: map[string]*IdempotentResponse{}, fingerprints: map[string]string{}}
+	calls := 0
+	handler := IdempotencyHandler(store, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			WriteError(w, r, NewServiceUnavailableError(errors.New("database is down")))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	send := func(key string, body string, capability ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/bookings", strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, key)
+		for _, c := range capability {
+			r.Header.Add("Capability", c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := send("key", `{"units": 1}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected first attempt to fail, got %d", w.Code)
+	}
+	if w := send("key", `{"units": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("expected failed request to be retried, got %d", w.Code)
+	}
+	w := send("key", `{"units": 1}`)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id": 1}` || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("expected successful response to be replayed, got %d %s", w.Code, w.Body.String())
+	}
+	if calls != 2 {
+		t.Fatalf("expected handler to be called twice, got %d", calls)
+	}
+	if w := send("key", `{"units": 2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected key reused for different request to be rejected, got %d", w.Code)
+	}
+	if w := send("key", `{"units": 1}`, "pricing"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected key reused with different capability to be rejected, got %d", w.Code)
+	}
+}
+
+type memoryIdempotencyStore struct {
+	mu           sync.Mutex
+	responses    map[string]*IdempotentResponse
+	fingerprints map[string]string
+}
+
+func (m *memoryIdempotencyStore) ReserveIdempotencyKey(_ context.Context, key string, fingerprint string) (*IdempotentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.fingerprints[key]
+	if !ok {
+		m.fingerprints[key] = fingerprint
+		return nil, nil
+	}
+	if stored != fingerprint {
+		return nil, NewDomainError(ErrValidation, ProblemCodeIdempotencyKeyReused, "reused")
+	}
+	if m.responses[key] == nil {
+		return nil, NewDomainError(ErrConflict, ProblemCodeIdempotencyKeyInUse, "in use")
+	}
+	return m.responses[key], nil
+}
+
+func (m *memoryIdempotencyStore) CompleteIdempotencyKey(_ context.Context, key string, response IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses[key] = &response
+	return nil
+}
+
+func (m *memoryIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.fingerprints, key)
+	return nil
+}
@@ -5,23 +5,137 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"strings"
+	"syscall"
 	"time"
 )
 
-func SetupLogger() {
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+type LoggingConfig struct {
+	// Format is one of LogFormatText, LogFormatJSON
+	Format    string     `yaml:"format"`
+	Level     slog.Level `yaml:"level"`
+	AddSource bool       `yaml:"addSource"`
+}
+
+// logLevel is shared by all loggers created by SetupLogger, so that it can be changed at runtime
+var logLevel = new(slog.LevelVar)
+
+func SetupLogger(cfg LoggingConfig) {
+	logLevel.Set(cfg.Level)
+	opts := &slog.HandlerOptions{
+		AddSource:   cfg.AddSource,
+		Level:       logLevel,
+		ReplaceAttr: RedactAttr,
+	}
+	var handler slog.Handler
+	if cfg.Format == LogFormatJSON {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
 	slog.SetDefault(
 		slog.New(
 			&slogHandlerWrapper{
-				Handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}),
+				Handler: handler,
 				extractors: []Extractor{
 					CorrelationIDExtractor,
+					TraceExtractor,
 				},
 			},
 		),
 	)
 }
 
+func LogLevel() slog.Level {
+	return logLevel.Level()
+}
+
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+	slog.Info("log level changed", slog.String("level", level.String()))
+}
+
+// ToggleDebugOnSignal switches log level between debug and level set before whenever process receives SIGHUP,
+// until ctx is done
+func ToggleDebugOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		previousLevel := LogLevel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if LogLevel() == slog.LevelDebug {
+					SetLogLevel(previousLevel)
+				} else {
+					previousLevel = LogLevel()
+					SetLogLevel(slog.LevelDebug)
+				}
+			}
+		}
+	}()
+}
+
+const redacted = "[REDACTED]"
+
+// keys of customer personal data, types holding it log their fields under these keys, so that they are redacted
+const (
+	PIIKeyFullName     = "fullName"
+	PIIKeyEmailAddress = "emailAddress"
+	PIIKeyPhoneNumber  = "phoneNumber"
+	PIIKeyLocale       = "locale"
+	PIIKeyCountry      = "country"
+	// PIIKeyNotes is free text of the guest, it can contain anything
+	PIIKeyNotes = "notes"
+)
+
+// PIIKeys are attribute keys of customer personal data, new personal data must be logged under one of them
+var PIIKeys = []string{
+	PIIKeyFullName,
+	PIIKeyEmailAddress,
+	PIIKeyPhoneNumber,
+	PIIKeyLocale,
+	PIIKeyCountry,
+	PIIKeyNotes,
+	"email",
+	"phone",
+	"contact_name",
+}
+
+// sensitiveKeys are attribute keys (lowercase) whose values must never reach logs, covers headers and customer PII
+var sensitiveKeys = newSensitiveKeys(
+	[]string{"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key", "password", "token"},
+	PIIKeys,
+)
+
+func newSensitiveKeys(keyLists ...[]string) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for _, list := range keyLists {
+		for _, key := range list {
+			keys[strings.ToLower(key)] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// RedactAttr replaces value of sensitive attributes, it is meant to be used as slog.HandlerOptions.ReplaceAttr
+func RedactAttr(_ []string, a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
 func Err(err error) slog.Attr {
 	return slog.String("err", err.Error())
 }
@@ -33,6 +147,20 @@ type slogHandlerWrapper struct {
 	extractors []Extractor
 }
 
+func (s *slogHandlerWrapper) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &slogHandlerWrapper{
+		Handler:    s.Handler.WithAttrs(attrs),
+		extractors: s.extractors,
+	}
+}
+
+func (s *slogHandlerWrapper) WithGroup(name string) slog.Handler {
+	return &slogHandlerWrapper{
+		Handler:    s.Handler.WithGroup(name),
+		extractors: s.extractors,
+	}
+}
+
 func (s *slogHandlerWrapper) Handle(ctx context.Context, record slog.Record) error {
 	for _, extractor := range s.extractors {
 		record.AddAttrs(extractor(ctx)...)
@@ -46,9 +174,12 @@ func CorrelationIDExtractor(ctx context.Context) []slog.Attr {
 	return []slog.Attr{slog.String("correlation_id", correlationID.String())}
 }
 
+// LoggingHandler logs every request and measures its metrics, route label is resolved when next is http.ServeMux
 func LoggingHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		route := routePattern(next, r)
+		nameServerSpan(r, route)
 		attrs := []any{
 			slog.Group(
 				"request",
@@ -59,10 +190,14 @@ func LoggingHandler(next http.Handler) http.Handler {
 				slog.String("user_agent", r.UserAgent()),
 			),
 		}
+		if slog.Default().Enabled(r.Context(), slog.LevelDebug) {
+			attrs = append(attrs, headersGroup(r.Header))
+		}
 
 		defer func() {
 			if err := recover(); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
+				observeRequest(route, r.Method, http.StatusInternalServerError, time.Since(start))
 				attrs = append(
 					attrs,
 					slog.Group("response",
@@ -90,6 +225,7 @@ func LoggingHandler(next http.Handler) http.Handler {
 		next.ServeHTTP(mw, r)
 
 		duration := time.Since(start)
+		observeRequest(route, r.Method, mw.statusCode, duration)
 		attrs = append(
 			attrs,
 			slog.Group(
@@ -109,6 +245,14 @@ func LoggingHandler(next http.Handler) http.Handler {
 	})
 }
 
+func headersGroup(header http.Header) slog.Attr {
+	attrs := make([]any, 0, len(header))
+	for name, values := range header {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.Group("headers", attrs...)
+}
+
 func CorrelationHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		correlationID := GetCorrelationIDReq(r)
@@ -128,3 +272,8 @@ func (m *metricsHttpWriter) WriteHeader(statusCode int) {
 	m.statusCode = statusCode
 	m.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Unwrap allows http.ResponseController to reach the underlying writer (e.g. to extend write deadline for streams)
+func (m *metricsHttpWriter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestRedactAttr(t *testing.T) {
+	buff := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buff, &slog.HandlerOptions{ReplaceAttr: RedactAttr}))
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("Accept", "application/json")
+	logger.Info("request", headersGroup(header), slog.String("Email", "guest@example.com"))
+
+	var record struct {
+		Headers map[string]string `json:"headers"`
+		Email   string            `json:"Email"`
+	}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Headers["authorization"] != redacted {
+		t.Fatalf("expected authorization header to be redacted, got %q", record.Headers["authorization"])
+	}
+	if record.Headers["accept"] != "application/json" {
+		t.Fatalf("expected accept header to be kept, got %q", record.Headers["accept"])
+	}
+	if record.Email != redacted {
+		t.Fatalf("expected email to be redacted, got %q", record.Email)
+	}
+}
+
+func TestRedactAttr_ContactFields(t *testing.T) {
+	buff := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buff, &slog.HandlerOptions{ReplaceAttr: RedactAttr}))
+
+	logger.Info(
+		"booking",
+		slog.Group(
+			"contact",
+			slog.String("fullName", "Jan Novák"),
+			slog.String("emailAddress", "jan@example.com"),
+			slog.String("phoneNumber", "+420123456789"),
+			slog.String("locale", "cs-CZ"),
+			slog.String("country", "CZ"),
+		),
+		slog.String("address", "0.0.0.0:8080"),
+	)
+
+	var record struct {
+		Contact map[string]string `json:"contact"`
+		Address string            `json:"address"`
+	}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"fullName", "emailAddress", "phoneNumber", "locale", "country"} {
+		if record.Contact[key] != redacted {
+			t.Fatalf("expected %s to be redacted, got %q", key, record.Contact[key])
+		}
+	}
+	if record.Address != "0.0.0.0:8080" {
+		t.Fatalf("expected listen address to be kept, got %q", record.Address)
+	}
+}
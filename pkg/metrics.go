@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that did not match any registered pattern, so that arbitrary paths do not blow up cardinality
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of finished HTTP requests.",
+		},
+		[]string{"method", "route", "code"},
+	)
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+)
+
+// RegisterHttpMetrics registers metrics measured by LoggingHandler
+func RegisterHttpMetrics(registerer prometheus.Registerer) error {
+	return errors.Join(
+		registerer.Register(httpRequestsTotal),
+		registerer.Register(httpRequestDuration),
+	)
+}
+
+// MetricsHandler exposes metrics in Prometheus text format
+func MetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	})
+}
+
+func observeRequest(route string, method string, statusCode int, duration time.Duration) {
+	httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(statusCode)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+type patternMatcher interface {
+	Handler(r *http.Request) (http.Handler, string)
+}
+
+// routePattern returns pattern that r matches in next, if next is a router (e.g. http.ServeMux)
+func routePattern(next http.Handler, r *http.Request) string {
+	matcher, ok := next.(patternMatcher)
+	if !ok {
+		return unmatchedRoute
+	}
+	if _, pattern := matcher.Handler(r); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
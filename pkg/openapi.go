@@ -0,0 +1,191 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+)
+
+func init() {
+	// uuid format is not validated by default, it is parsed the same way handlers parse ids
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		_, err := uuid.Parse(value)
+		return err
+	})
+}
+
+// OpenAPIValidationHandler validates requests of operations documented in spec before they reach next, invalid
+// requests are answered with BadRequestError. Requests of undocumented routes (e.g. metrics) are passed to next as they are.
+// When validateResponses is set, responses are buffered and validated too and an invalid response is replaced
+// with internal server error, it is meant for tests to catch handlers drifting from spec.
+func OpenAPIValidationHandler(spec []byte, validateResponses bool, next http.Handler) (http.Handler, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("loading open api spec failed: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("open api spec is invalid: %w", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("creating open api router failed: %w", err)
+	}
+	return &openAPIValidationHandler{
+		router:            router,
+		validateResponses: validateResponses,
+		next:              next,
+	}, nil
+}
+
+var _ patternMatcher = &openAPIValidationHandler{}
+
+type openAPIValidationHandler struct {
+	router            routers.Router
+	validateResponses bool
+	next              http.Handler
+}
+
+// Handler returns handler and pattern of next, so that routes are still reported by logging and metrics
+func (o *openAPIValidationHandler) Handler(r *http.Request) (http.Handler, string) {
+	if matcher, ok := o.next.(patternMatcher); ok {
+		return matcher.Handler(r)
+	}
+	return o.next, ""
+}
+
+func (o *openAPIValidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, pathParams, err := o.router.FindRoute(r)
+	if err != nil {
+		o.next.ServeHTTP(w, r)
+		return
+	}
+
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+	if err := openapi3filter.ValidateRequest(r.Context(), requestInput); err != nil {
+		WriteError(w, r, NewBadRequestError(invalidParamsFromError(err)...))
+		return
+	}
+
+	if !o.validateResponses || streamsResponse(route.Operation) {
+		o.next.ServeHTTP(w, r)
+		return
+	}
+
+	buffer := newBufferedResponseWriter()
+	o.next.ServeHTTP(buffer, r)
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 buffer.status,
+		Header:                 buffer.Header(),
+		Options: &openapi3filter.Options{
+			MultiError:            true,
+			IncludeResponseStatus: true,
+		},
+	}
+	responseInput.SetBodyBytes(buffer.body.Bytes())
+	if err := openapi3filter.ValidateResponse(r.Context(), responseInput); err != nil {
+		WriteError(w, r, NewInternalServerError(fmt.Errorf("response of %s %s does not match open api spec: %w", r.Method, route.Path, err)))
+		return
+	}
+	buffer.writeTo(w)
+}
+
+// streamsResponse reports operations answering with server-sent events, those can not be buffered
+func streamsResponse(operation *openapi3.Operation) bool {
+	for _, response := range operation.Responses.Map() {
+		if response.Value != nil && response.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidParamsFromError lists every violation of err reported by openapi3filter, body violations are named by their
+// path in the body
+func invalidParamsFromError(err error) []InvalidParam {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		invalidParams := make([]InvalidParam, 0, len(e))
+		for _, inner := range e {
+			invalidParams = append(invalidParams, invalidParamsFromError(inner)...)
+		}
+		return invalidParams
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			return []InvalidParam{{Name: e.Parameter.Name, Reason: requestErrorReason(e)}}
+		}
+		if e.Err != nil {
+			return invalidParamsFromError(e.Err)
+		}
+		return []InvalidParam{{Name: "Body", Reason: e.Reason}}
+	case *openapi3.SchemaError:
+		name := strings.Join(e.JSONPointer(), ".")
+		if name == "" {
+			name = "Body"
+		}
+		return []InvalidParam{{Name: name, Reason: e.Reason}}
+	default:
+		return []InvalidParam{{Name: "Body", Reason: err.Error()}}
+	}
+}
+
+func requestErrorReason(e *openapi3filter.RequestError) string {
+	if e.Err == nil {
+		return e.Reason
+	}
+	reasons := make([]string, 0, 1)
+	for _, invalidParam := range invalidParamsFromError(e.Err) {
+		reasons = append(reasons, invalidParam.Reason)
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// bufferedResponseWriter holds response until it is validated
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: http.Header{},
+		status: http.StatusOK,
+	}
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) Write(bytes []byte) (int, error) {
+	return b.body.Write(bytes)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(statusCode int) {
+	b.status = statusCode
+}
+
+func (b *bufferedResponseWriter) writeTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.status)
+	_, _ = b.body.WriteTo(w)
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: test
+  version: 1.0.0
+paths:
+  /count:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+      responses:
+        '200':
+          description: Success
+          content:
+            application/json:
+              schema:
+                type: object
+                required:
+                  - count
+                properties:
+                  count:
+                    type: integer
+`
+
+func TestOpenAPIValidationHandler(t *testing.T) {
+	tests := []struct {
+		name              string
+		url               string
+		response          string
+		validateResponses bool
+		status            int
+	}{
+		{name: "valid", url: "/count?limit=1", response: `{"count": 1}`, validateResponses: true, status: http.StatusOK},
+		{name: "invalid request", url: "/count?limit=one", response: `{"count": 1}`, status: http.StatusBadRequest},
+		{name: "response drift is reported", url: "/count", response: `{"count": "one"}`, validateResponses: true, status: http.StatusInternalServerError},
+		{name: "responses are not validated by default", url: "/count", response: `{"count": "one"}`, status: http.StatusOK},
+		{name: "undocumented route is passed through", url: "/metrics", response: `metrics`, validateResponses: true, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			})
+			handler, err := OpenAPIValidationHandler([]byte(testSpec), tt.validateResponses, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProblemTypeBaseURI is base of problem type URIs, every problem code is documented under its own anchor
+const ProblemTypeBaseURI = "https://github.com/prathoss/ventrata-hw/blob/main/docs/problems.md#"
+
+// codes of problems that are not specific to any domain
+const (
+	ProblemCodeValidationFailed   = "VALIDATION_FAILED"
+	ProblemCodeNotFound           = "NOT_FOUND"
+	ProblemCodeConflict           = "CONFLICT"
+	ProblemCodeGone               = "GONE"
+	ProblemCodeUnprocessable      = "UNPROCESSABLE"
+	ProblemCodeInternal           = "INTERNAL_ERROR"
+	ProblemCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	ProblemCodeUnauthorized       = "UNAUTHORIZED"
+	ProblemCodeForbidden          = "FORBIDDEN"
+	ProblemCodeRateLimited        = "RATE_LIMITED"
+	// ProblemCodeIdempotencyKeyReused is reported when Idempotency-Key is sent with a different request
+	ProblemCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	// ProblemCodeIdempotencyKeyInUse is reported when request with the same Idempotency-Key is still in progress
+	ProblemCodeIdempotencyKeyInUse = "IDEMPOTENCY_KEY_IN_USE"
+)
+
+// ProblemTypeURI returns type URI of problem with code
+func ProblemTypeURI(code string) string {
+	return ProblemTypeBaseURI + strings.ToLower(code)
+}
+
+// NewProblemDetail creates problem detail of request r
+func NewProblemDetail(r *http.Request, status int, code string, title string, detail string) ProblemDetail {
+	return ProblemDetail{
+		Status:        status,
+		Type:          ProblemTypeURI(code),
+		Title:         title,
+		Detail:        detail,
+		Instance:      r.URL.Path,
+		Code:          code,
+		CorrelationID: GetCorrelationIDCtx(r.Context()),
+	}
+}
+
+// writeProblem writes problem as response, headers must be set before it is called
+func writeProblem(w http.ResponseWriter, status int, problem any) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+var _ error = &ConflictError{}
+var _ HttpProblemWriter = &ConflictError{}
+
+// NewConflictError reports that request conflicts with current state of the resource, e.g. it is sold out
+func NewConflictError(code string, message string) *ConflictError {
+	return &ConflictError{
+		code:    code,
+		message: message,
+	}
+}
+
+type ConflictError struct {
+	code    string
+	message string
+}
+
+func (c *ConflictError) Error() string {
+	return c.message
+}
+
+// Code returns problem code of the conflict
+func (c *ConflictError) Code() string {
+	return c.code
+}
+
+func (c *ConflictError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	detail := NewProblemDetail(r, http.StatusConflict, c.code, "Request conflicts with current state of the resource", c.message)
+	return writeProblem(w, detail.Status, detail)
+}
+
+var _ error = &GoneError{}
+var _ HttpProblemWriter = &GoneError{}
+
+// NewGoneError reports that resource existed but is not available anymore, e.g. its day has passed
+func NewGoneError(code string, message string) *GoneError {
+	return &GoneError{
+		code:    code,
+		message: message,
+	}
+}
+
+type GoneError struct {
+	code    string
+	message string
+}
+
+func (g *GoneError) Error() string {
+	return g.message
+}
+
+// Code returns problem code of the gone resource
+func (g *GoneError) Code() string {
+	return g.code
+}
+
+func (g *GoneError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	detail := NewProblemDetail(r, http.StatusGone, g.code, "Resource is no longer available", g.message)
+	return writeProblem(w, detail.Status, detail)
+}
+
+var _ error = &UnprocessableEntityError{}
+var _ HttpProblemWriter = &UnprocessableEntityError{}
+
+// NewUnprocessableEntityError reports well-formed request that breaks business rules
+func NewUnprocessableEntityError(code string, message string, invalidParams ...InvalidParam) *UnprocessableEntityError {
+	return &UnprocessableEntityError{
+		code:          code,
+		message:       message,
+		invalidParams: invalidParams,
+	}
+}
+
+type UnprocessableEntityError struct {
+	code          string
+	message       string
+	invalidParams []InvalidParam
+}
+
+func (u *UnprocessableEntityError) Error() string {
+	return u.message
+}
+
+// Code returns problem code of the broken rule
+func (u *UnprocessableEntityError) Code() string {
+	return u.code
+}
+
+func (u *UnprocessableEntityError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	detail := ValidationProblemDetail{
+		ProblemDetail: NewProblemDetail(r, http.StatusUnprocessableEntity, u.code, "Request could not be processed", u.message),
+		InvalidParams: u.invalidParams,
+	}
+	return writeProblem(w, detail.Status, detail)
+}
+
+var _ error = &ForbiddenError{}
+var _ HttpProblemWriter = &ForbiddenError{}
+
+func NewForbiddenError(message string) *ForbiddenError {
+	return &ForbiddenError{
+		message: message,
+	}
+}
+
+type ForbiddenError struct {
+	message string
+}
+
+func (f *ForbiddenError) Error() string {
+	return f.message
+}
+
+func (f *ForbiddenError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	detail := NewProblemDetail(r, http.StatusForbidden, ProblemCodeForbidden, "Access is forbidden", f.message)
+	return writeProblem(w, detail.Status, detail)
+}
+
+var _ error = &TooManyRequestsError{}
+var _ HttpProblemWriter = &TooManyRequestsError{}
+
+// NewTooManyRequestsError reports that client exceeded its rate limit, it may retry after retryAfter
+func NewTooManyRequestsError(retryAfter time.Duration) *TooManyRequestsError {
+	return &TooManyRequestsError{
+		retryAfter: retryAfter,
+	}
+}
+
+type TooManyRequestsError struct {
+	retryAfter time.Duration
+}
+
+func (t *TooManyRequestsError) Error() string {
+	return "too many requests"
+}
+
+func (t *TooManyRequestsError) WriteProblem(w http.ResponseWriter, r *http.Request) error {
+	// Retry-After is in whole seconds, round up so that client does not retry too early
+	retryAfter := (t.retryAfter + time.Second - 1) / time.Second
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+	detail := NewProblemDetail(r, http.StatusTooManyRequests, ProblemCodeRateLimited, "Too many requests", "")
+	return writeProblem(w, detail.Status, detail)
+}
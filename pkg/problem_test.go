@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteError_Problems(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "conflict", err: NewConflictError("AVAILABILITY_SOLD_OUT", "sold out"), status: http.StatusConflict, code: "AVAILABILITY_SOLD_OUT"},
+		{name: "gone", err: NewGoneError("AVAILABILITY_EXPIRED", "passed"), status: http.StatusGone, code: "AVAILABILITY_EXPIRED"},
+		{name: "unprocessable", err: NewUnprocessableEntityError("OUTSIDE_SALES_WINDOW", "outside"), status: http.StatusUnprocessableEntity, code: "OUTSIDE_SALES_WINDOW"},
+		{name: "unauthorized", err: NewUnauthorizedError("missing"), status: http.StatusUnauthorized, code: ProblemCodeUnauthorized},
+		{name: "forbidden", err: NewForbiddenError("denied"), status: http.StatusForbidden, code: ProblemCodeForbidden},
+		{name: "too many requests", err: NewTooManyRequestsError(1500 * time.Millisecond), status: http.StatusTooManyRequests, code: ProblemCodeRateLimited},
+		{name: "unknown error", err: http.ErrHandlerTimeout, status: http.StatusInternalServerError, code: ProblemCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			correlationID := uuid.New()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/bookings", nil)
+			r = r.WithContext(SetCorrelationID(r.Context(), correlationID))
+			w := httptest.NewRecorder()
+
+			WriteError(w, r, tt.err)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Fatalf("expected problem content type, got %s", contentType)
+			}
+			var detail ProblemDetail
+			if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+				t.Fatal(err)
+			}
+			if detail.Code != tt.code || detail.Type != ProblemTypeURI(tt.code) {
+				t.Fatalf("expected code %s with its type, got %s %s", tt.code, detail.Code, detail.Type)
+			}
+			if detail.Instance != "/api/v1/bookings" {
+				t.Fatalf("expected instance to be request path, got %s", detail.Instance)
+			}
+			if detail.CorrelationID != correlationID {
+				t.Fatalf("expected correlation ID %s, got %s", correlationID, detail.CorrelationID)
+			}
+		})
+	}
+}
+
+func TestTooManyRequestsError_RetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), NewTooManyRequestsError(1500*time.Millisecond))
+
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Fatalf("expected Retry-After to be rounded up to 2, got %s", retryAfter)
+	}
+}
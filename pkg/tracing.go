@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/prathoss/hw"
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+type TracingConfig struct {
+	// Exporter is one of TracingExporterNone, TracingExporterStdout, TracingExporterOTLP
+	Exporter string `yaml:"exporter"`
+	// Endpoint is OTLP HTTP endpoint URL, when empty OTEL_EXPORTER_OTLP_* environment variables are used
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"serviceName"`
+}
+
+// SetupTracing sets up global tracer provider and W3C trace context propagation,
+// returned function flushes remaining spans and has to be called before exit
+func SetupTracing(ctx context.Context, cfg TracingConfig) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case TracingExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterOTLP:
+		opts := make([]otlptracehttp.Option, 0, 1)
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s tracing exporter failed: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating tracing resource failed: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// TracingHandler starts server span for every request, continuing trace from traceparent header if present
+func TracingHandler(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server")
+}
+
+// nameServerSpan names server span started by TracingHandler after matched route, so that spans are grouped by route
+func nameServerSpan(r *http.Request, route string) {
+	span := trace.SpanFromContext(r.Context())
+	span.SetName(fmt.Sprintf("%s %s", r.Method, route))
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+func TraceExtractor(ctx context.Context) []slog.Attr {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []slog.Attr{
+		slog.String("trace_id", spanContext.TraceID().String()),
+		slog.String("span_id", spanContext.SpanID().String()),
+	}
+}
+
+var _ pgx.QueryTracer = &PgxTracer{}
+var _ pgx.CopyFromTracer = &PgxTracer{}
+
+// PgxTracer creates child span for every query and copy executed by pgx connection
+type PgxTracer struct{}
+
+func (p *PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = otel.Tracer(tracerName).Start(
+		ctx,
+		"db.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatement(data.SQL),
+		),
+	)
+	return ctx
+}
+
+func (p *PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	endDbSpan(span, data.Err, data.CommandTag.RowsAffected())
+}
+
+func (p *PgxTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx, _ = otel.Tracer(tracerName).Start(
+		ctx,
+		"db.copy_from",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBSQLTable(data.TableName.Sanitize()),
+		),
+	)
+	return ctx
+}
+
+func (p *PgxTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	span := trace.SpanFromContext(ctx)
+	endDbSpan(span, data.Err, data.CommandTag.RowsAffected())
+}
+
+func endDbSpan(span trace.Span, err error, rowsAffected int64) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+	}
+	span.End()
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupTracing_OTLP(t *testing.T) {
+	var exportedBatches atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/x-protobuf" {
+			t.Errorf("expected protobuf export, got content type %s", contentType)
+		}
+		exportedBatches.Add(1)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(collector.Close)
+
+	ctx := context.Background()
+	shutdown, err := SetupTracing(ctx, TracingConfig{
+		Exporter:    TracingExporterOTLP,
+		Endpoint:    collector.URL,
+		ServiceName: "hw-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := TracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := otel.Tracer(tracerName).Start(r.Context(), "child")
+		span.End()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/products", nil))
+
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exportedBatches.Load() == 0 {
+		t.Fatal("expected spans to be exported to collector")
+	}
+}
+
+func TestTracingHandler_Propagation(t *testing.T) {
+	shutdown, err := SetupTracing(context.Background(), TracingConfig{
+		Exporter:    TracingExporterStdout,
+		ServiceName: "hw-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = shutdown(context.Background())
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var spanContext trace.SpanContext
+	var extracted map[string]string
+	handler := TracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spanContext = trace.SpanContextFromContext(r.Context())
+		extracted = map[string]string{}
+		for _, attr := range TraceExtractor(r.Context()) {
+			extracted[attr.Key] = attr.Value.String()
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if spanContext.TraceID().String() != traceID {
+		t.Fatalf("expected trace %s to be continued, got %s", traceID, spanContext.TraceID())
+	}
+	if extracted["trace_id"] != traceID {
+		t.Fatalf("expected extracted trace_id %s, got %s", traceID, extracted["trace_id"])
+	}
+	if extracted["span_id"] != spanContext.SpanID().String() {
+		t.Fatalf("expected extracted span_id %s, got %s", spanContext.SpanID(), extracted["span_id"])
+	}
+}
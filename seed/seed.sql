@@ -1,9 +1,9 @@
-INSERT INTO ventrata.products (id, name, capacity)
+INSERT INTO ventrata.products (id, name, capacity, horizon_days, lead_time_minutes)
 VALUES
-('9D51D042-96B7-446B-B152-97D451D33933', 'Museum entry', 300),
-('FBBEE9F5-0539-499B-8DA2-41AA7BCDF16F', 'Concert ticket', 2000),
-('9DBBDC3D-8B5E-4DBB-813F-43D8CFEF4E38', 'Hop-On-Hop-Of bus ticket', 20),
-('C695D47E-1B44-4189-9171-0B449A4D81D1', 'Private excursion', 5);
+('9D51D042-96B7-446B-B152-97D451D33933', 'Museum entry', 300, 365, 0),
+('FBBEE9F5-0539-499B-8DA2-41AA7BCDF16F', 'Concert ticket', 2000, 730, 0),
+('9DBBDC3D-8B5E-4DBB-813F-43D8CFEF4E38', 'Hop-On-Hop-Of bus ticket', 20, 365, 0),
+('C695D47E-1B44-4189-9171-0B449A4D81D1', 'Private excursion', 5, 30, 120);
 
 INSERT INTO ventrata.pricing (product_id, currency, price)
 VALUES